@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// ArchiveFileExtension is used for ZIP archives with all generated report files.
+const archiveFileExtension = ".zip"
+
+// ArchiveManifestName is the name of a file in each archive which lists all report files.
+const archiveManifestName = "manifest.json"
+
+// ArchiveManifest lists all report files of an archive.
+type archiveManifest struct {
+	CreatedAt time.Time             `json:"createdAt"`
+	Files     []archiveManifestFile `json:"files"`
+}
+
+// ArchiveManifestFile contains name, size and SHA-256 checksum of a single report file.
+type archiveManifestFile struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// NewReportArchive packs all passed report files into a ZIP archive. A manifest with name, size
+// and SHA-256 checksum of each file is added as manifest.json.
+func newReportArchive(files []reportFile) (*bytes.Buffer, error) {
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	manifest := archiveManifest{CreatedAt: createdAt, Files: []archiveManifestFile{}}
+
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	for _, file := range files {
+		checksum := sha256.Sum256(file.content)
+		manifest.Files = append(manifest.Files, archiveManifestFile{
+			Name:   file.name,
+			Size:   len(file.content),
+			Sha256: hex.EncodeToString(checksum[:]),
+		})
+		if err := writeToArchive(writer, file.name, file.content, createdAt); err != nil {
+			return nil, err
+		}
+	}
+
+	manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeToArchive(writer, archiveManifestName, manifestContent, createdAt); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// WriteToArchive adds a compressed file with given name and content to an archive.
+func writeToArchive(writer *zip.Writer, name string, content []byte, modified time.Time) error {
+	fileWriter, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = fileWriter.Write(content)
+	return err
+}
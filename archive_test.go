@@ -0,0 +1,61 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ArchiveTestSuite struct {
+	suite.Suite
+}
+
+func TestArchiveTestSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveTestSuite))
+}
+
+func (suite *ArchiveTestSuite) TestNewReportArchive() {
+
+	files := []reportFile{
+		{name: "report_202201.csv", content: []byte("Date,Type\n2022-01-03,WORKDAY\n")},
+		{name: "report_202201.json", content: []byte("{}")},
+	}
+	buf, err := newReportArchive(files)
+	suite.Nil(err)
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	suite.Nil(err)
+	suite.Len(reader.File, 3)
+	suite.Equal("report_202201.csv", reader.File[0].Name)
+	suite.Equal("report_202201.json", reader.File[1].Name)
+	suite.Equal(archiveManifestName, reader.File[2].Name)
+
+	csvContent := suite.readFile(reader.File[0])
+	suite.Equal(files[0].content, csvContent)
+
+	manifest := archiveManifest{}
+	suite.Nil(json.Unmarshal(suite.readFile(reader.File[2]), &manifest))
+	suite.False(manifest.CreatedAt.IsZero())
+	suite.Len(manifest.Files, 2)
+	for idx, file := range files {
+		checksum := sha256.Sum256(file.content)
+		suite.Equal(file.name, manifest.Files[idx].Name)
+		suite.Equal(len(file.content), manifest.Files[idx].Size)
+		suite.Equal(hex.EncodeToString(checksum[:]), manifest.Files[idx].Sha256)
+	}
+}
+
+func (suite *ArchiveTestSuite) readFile(file *zip.File) []byte {
+	reader, err := file.Open()
+	suite.Nil(err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	suite.Nil(err)
+	return content
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	core "github.com/tommzn/hob-core"
+	"google.golang.org/protobuf/proto"
+)
+
+// Status of a single month of a backfill.
+const (
+	backfillStatusGenerated = "generated"
+	backfillStatusSkipped   = "skipped"
+	backfillStatusFailed    = "failed"
+)
+
+// ChecksumFileExtension is appended to a report name to store the checksum of a delivered report.
+const checksumFileExtension = ".sha256"
+
+// BackfillResult is the outcome of report generation for a single month.
+type backfillResult struct {
+	Month  time.Time
+	Status string
+	Err    error
+}
+
+// Backfill generates monthly reports for all months from start until end month, both included. Months are generated
+// concurrently by given number of workers. A report which has already been delivered with identical content to a file
+// or S3 target of passed request is skipped, use force to deliver all reports again.
+func (handler *ReportGenerator) Backfill(ctx context.Context, request *reportRequest, start, end time.Time, concurrency int, force bool) []backfillResult {
+
+	months := backfillMonths(start, end)
+	results := make([]backfillResult, len(months))
+
+	checksums := handler.newChecksumStore(request)
+	if force && checksums != nil {
+		checksums = &forcedChecksumStore{checksumStore: checksums}
+	}
+
+	runConcurrently(len(months), concurrency, func(idx int) {
+		results[idx] = handler.backfillMonth(ctx, request, months[idx], checksums)
+	})
+	return results
+}
+
+// BackfillMonth generates a monthly report for given month. Each month is generated by its own pipeline,
+// so multiple months can be generated at the same time.
+func (handler *ReportGenerator) backfillMonth(ctx context.Context, request *reportRequest, month time.Time, checksums checksumStore) backfillResult {
+
+	monthRequest := &reportRequest{
+		GenerateReportRequest: proto.Clone(request.GenerateReportRequest).(*core.GenerateReportRequest),
+		reportOptions:         request.reportOptions,
+	}
+	monthRequest.Type = core.ReportType_MONTHLY_REPORT
+	monthRequest.Year = int64(month.Year())
+	monthRequest.Month = int64(month.Month())
+
+	result := backfillResult{Month: month, Status: backfillStatusGenerated}
+	pipeline, err := handler.newPipeline(ctx, monthRequest)
+	if err == nil {
+		pipeline.checksums = checksums
+		err = pipeline.run(monthRequest)
+	}
+	if err != nil {
+		if err == errReportUnchanged {
+			result.Status = backfillStatusSkipped
+		} else {
+			result.Status = backfillStatusFailed
+			result.Err = err
+		}
+	}
+	return result
+}
+
+// BackfillMonths returns first day of all months from start until end, both included.
+func backfillMonths(start, end time.Time) []time.Time {
+	months := []time.Time{}
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(end); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	return months
+}
+
+// WriteBackfillSummary writes a table with status of each month and total number of generated, skipped and failed months.
+func writeBackfillSummary(output io.Writer, results []backfillResult) {
+
+	totals := make(map[string]int)
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "MONTH\tSTATUS\tERROR")
+	for _, result := range results {
+		reason := ""
+		if result.Err != nil {
+			reason = strings.ReplaceAll(result.Err.Error(), "\n", " ")
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", result.Month.Format("2006-01"), result.Status, reason)
+		totals[result.Status]++
+	}
+	writer.Flush()
+	fmt.Fprintf(output, "%d generated, %d skipped, %d failed\n", totals[backfillStatusGenerated], totals[backfillStatusSkipped], totals[backfillStatusFailed])
+}
+
+// ReportChecksum returns a SHA-256 checksum of report data and all settings which have an impact on delivered files,
+// including delivery targets. Some formats, e.g. excel, contain a creation time, so checksums of generated files
+// can't be used to detect changes.
+func reportChecksum(request *reportRequest, report *generatedReport) string {
+
+	formats, _ := request.reportFormats()
+	targets, _ := request.deliveryTargets()
+	delivery, _ := deliveryJson(request)
+	content, _ := json.Marshal(struct {
+		Formats     []core.ReportFormat
+		NamePattern string
+		Delivery    json.RawMessage
+		Targets     []string
+		Archive     bool
+		Mail        mailOptions
+		Chat        chatOptions
+		Report      *generatedReport
+	}{
+		Formats:     formats,
+		NamePattern: request.NamePattern,
+		Delivery:    delivery,
+		Targets:     targets,
+		Archive:     request.Archive,
+		Mail:        request.Mail,
+		Chat:        request.Chat,
+		Report:      report,
+	})
+	return checksumOf(content)
+}
+
+// NewChecksumStore returns a store for checksums of delivered reports in a file or S3 target of passed request.
+// Returns nil if there's no such target.
+func (handler *ReportGenerator) newChecksumStore(request *reportRequest) checksumStore {
+
+	if request.Delivery == nil {
+		return nil
+	}
+	if request.Delivery.File != nil {
+		return &fileChecksumStore{path: request.Delivery.File.Path}
+	}
+	if request.Delivery.S3 != nil {
+		region, bucket, basePath := handler.s3Target(request.Delivery.S3)
+		return &s3ChecksumStore{
+			client:   s3.New(session.Must(session.NewSession(&aws.Config{Region: region}))),
+			bucket:   bucket,
+			basePath: basePath,
+		}
+	}
+	return nil
+}
+
+// FileChecksumStore keeps checksums of delivered reports in a local directory.
+type fileChecksumStore struct {
+	path string
+}
+
+// Checksum reads the checksum of a report from a local file.
+func (store *fileChecksumStore) checksum(name string) (string, error) {
+	content, err := os.ReadFile(store.path + name + checksumFileExtension)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(content)), err
+}
+
+// SaveChecksum writes the checksum of a report to a local file.
+func (store *fileChecksumStore) saveChecksum(name, checksum string) error {
+	return os.WriteFile(store.path+name+checksumFileExtension, []byte(checksum), 0644)
+}
+
+// S3ChecksumStore keeps checksums of delivered reports in a S3 bucket.
+type s3ChecksumStore struct {
+	client   s3iface.S3API
+	bucket   *string
+	basePath *string
+}
+
+// Checksum reads the checksum of a report from a S3 object.
+func (store *s3ChecksumStore) checksum(name string) (string, error) {
+
+	output, err := store.client.GetObject(&s3.GetObjectInput{
+		Bucket: store.bucket,
+		Key:    store.objectKey(name),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return "", nil
+		}
+		return "", err
+	}
+	defer output.Body.Close()
+	content, err := io.ReadAll(output.Body)
+	return strings.TrimSpace(string(content)), err
+}
+
+// SaveChecksum writes the checksum of a report to a S3 object.
+func (store *s3ChecksumStore) saveChecksum(name, checksum string) error {
+	_, err := store.client.PutObject(&s3.PutObjectInput{
+		Bucket: store.bucket,
+		Key:    store.objectKey(name),
+		Body:   bytes.NewReader([]byte(checksum)),
+	})
+	return err
+}
+
+// ObjectKey returns the key of a checksum object. Same as for reports a base path is added as prefix.
+func (store *s3ChecksumStore) objectKey(name string) *string {
+	key := name + checksumFileExtension
+	if store.basePath != nil {
+		key = strings.TrimSuffix(*store.basePath, "/") + "/" + key
+	}
+	return &key
+}
+
+// ForcedChecksumStore never returns a checksum of a delivered report, so all reports are delivered again.
+// Checksums are still saved.
+type forcedChecksumStore struct {
+	checksumStore
+}
+
+// Checksum returns always an empty checksum.
+func (store *forcedChecksumStore) checksum(name string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/suite"
+	core "github.com/tommzn/hob-core"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type BackfillTestSuite struct {
+	suite.Suite
+}
+
+func TestBackfillTestSuite(t *testing.T) {
+	suite.Run(t, new(BackfillTestSuite))
+}
+
+func (suite *BackfillTestSuite) TestBackfill() {
+
+	path := suite.T().TempDir() + "/"
+	handler := reportGeneratorForTest()
+	request := suite.requestForTest(path)
+	start, end := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	results1 := handler.Backfill(context.Background(), request, start, end, 2, false)
+	suite.assertStatus(results1, backfillStatusGenerated, backfillStatusGenerated, backfillStatusGenerated)
+	for _, fileName := range []string{"Report_202201.csv", "Report_202202.csv", "Report_202203.csv", "Report_202203.sha256"} {
+		suite.FileExists(path + fileName)
+	}
+
+	results2 := handler.Backfill(context.Background(), request, start, end, 2, false)
+	suite.assertStatus(results2, backfillStatusSkipped, backfillStatusSkipped, backfillStatusSkipped)
+
+	suite.Nil(handler.timeTracker.Captured("Device01", timetracker.WORKDAY, time.Date(2022, 2, 8, 8, 0, 0, 0, time.UTC)))
+	suite.Nil(handler.timeTracker.Captured("Device01", timetracker.WORKDAY, time.Date(2022, 2, 8, 16, 0, 0, 0, time.UTC)))
+	results3 := handler.Backfill(context.Background(), request, start, end, 2, false)
+	suite.assertStatus(results3, backfillStatusSkipped, backfillStatusGenerated, backfillStatusSkipped)
+
+	results4 := handler.Backfill(context.Background(), request, start, end, 1, true)
+	suite.assertStatus(results4, backfillStatusGenerated, backfillStatusGenerated, backfillStatusGenerated)
+}
+
+func (suite *BackfillTestSuite) TestBackfillWithFailedMonths() {
+
+	handler := reportGeneratorForTest()
+	request := suite.requestForTest(filepath.Join(suite.T().TempDir(), "missing") + "/")
+	results := handler.Backfill(context.Background(), request, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), 0, false)
+	suite.assertStatus(results, backfillStatusFailed, backfillStatusFailed)
+	suite.NotNil(results[0].Err)
+}
+
+func (suite *BackfillTestSuite) TestBackfillWithoutChecksums() {
+
+	handler := reportGeneratorForTest()
+	request := suite.requestForTest("")
+	request.Delivery = &core.ReportDelivery{}
+	publisher := &responsePublisher{}
+	suite.Nil(handler.newChecksumStore(request))
+	suite.Nil(handler.processRequest(context.Background(), request, publisher))
+	suite.Nil(handler.processRequest(context.Background(), request, publisher))
+	suite.Len(publisher.files, 2)
+}
+
+func (suite *BackfillTestSuite) TestBackfillMonths() {
+
+	months := backfillMonths(time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC))
+	suite.Len(months, 4)
+	suite.Equal(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC), months[1])
+	suite.Equal(time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), months[3])
+	suite.Len(backfillMonths(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)), 1)
+}
+
+func (suite *BackfillTestSuite) TestWriteBackfillSummary() {
+
+	output := new(bytes.Buffer)
+	writeBackfillSummary(output, []backfillResult{
+		{Month: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Status: backfillStatusGenerated},
+		{Month: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), Status: backfillStatusSkipped},
+		{Month: time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), Status: backfillStatusFailed, Err: errors.New("Upload failed")},
+	})
+	suite.Contains(output.String(), "MONTH    STATUS     ERROR")
+	suite.Contains(output.String(), "2022-03  failed     Upload failed")
+	suite.Contains(output.String(), "1 generated, 1 skipped, 1 failed")
+}
+
+func (suite *BackfillTestSuite) TestS3ChecksumStore() {
+
+	client := &s3ClientMock{objects: make(map[string]string)}
+	store := &s3ChecksumStore{client: client, bucket: aws.String("reports"), basePath: aws.String("backfill/")}
+
+	checksum, err := store.checksum("Report_202201")
+	suite.Nil(err)
+	suite.Equal("", checksum)
+
+	suite.Nil(store.saveChecksum("Report_202201", "xxx"))
+	suite.Equal("xxx", client.objects["backfill/Report_202201.sha256"])
+
+	checksum, err = store.checksum("Report_202201")
+	suite.Nil(err)
+	suite.Equal("xxx", checksum)
+
+	client.err = errors.New("Access denied")
+	_, err = store.checksum("Report_202201")
+	suite.NotNil(err)
+}
+
+func (suite *BackfillTestSuite) TestReportChecksum() {
+
+	request := suite.requestForTest("")
+	report := generatedReportForTest()
+	checksum := reportChecksum(request, report)
+	suite.Len(checksum, 64)
+	suite.Equal(checksum, reportChecksum(request, generatedReportForTest()))
+
+	request.Formats = []string{"json"}
+	suite.NotEqual(checksum, reportChecksum(request, report))
+
+	request = suite.requestForTest("")
+	request.Delivery.Mail = &core.MailTarget{ToAddresses: []string{"user@example.com"}}
+	suite.NotEqual(checksum, reportChecksum(request, report))
+
+	request = suite.requestForTest("")
+	request.Targets = []string{"sftp"}
+	suite.NotEqual(checksum, reportChecksum(request, report))
+
+	request = suite.requestForTest("")
+	request.Chat = chatOptions{Channels: []string{"team"}}
+	suite.NotEqual(checksum, reportChecksum(request, report))
+}
+
+func (suite *BackfillTestSuite) requestForTest(path string) *reportRequest {
+	return &reportRequest{
+		GenerateReportRequest: &core.GenerateReportRequest{
+			Type:        core.ReportType_MONTHLY_REPORT,
+			Format:      reportFormatCsv,
+			NamePattern: "Report_200601",
+			DeviceIds:   []string{"Device01"},
+			Delivery:    &core.ReportDelivery{File: &core.FileTarget{Path: path}},
+		},
+	}
+}
+
+func (suite *BackfillTestSuite) assertStatus(results []backfillResult, expectedStatus ...string) {
+	suite.Len(results, len(expectedStatus))
+	for idx, status := range expectedStatus {
+		suite.Equal(status, results[idx].Status, results[idx].Month.Format("2006-01"))
+	}
+}
+
+// S3ClientMock keeps all objects in memory.
+type s3ClientMock struct {
+	s3iface.S3API
+	objects map[string]string
+	err     error
+}
+
+func (mock *s3ClientMock) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	content, ok := mock.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "Not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte(content)))}, nil
+}
+
+func (mock *s3ClientMock) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	content, _ := io.ReadAll(input.Body)
+	mock.objects[*input.Key] = string(content)
+	return &s3.PutObjectOutput{}, nil
+}
@@ -27,7 +27,7 @@ func (suite *BootstrapTestSuite) TestCreateLocaleFromConfig() {
 	locale := newLocale(conf)
 	suite.Equal("NL", locale.Country)
 	suite.NotNil(locale.Timezone)
-	suite.Equal("Europe/Rom", *locale.Timezone)
+	suite.Equal("Europe/Rome", *locale.Timezone)
 	suite.NotNil(locale.DateFormat)
 	suite.Equal("2006/01/02", *locale.DateFormat)
 
@@ -65,12 +65,37 @@ func (suite *BootstrapTestSuite) TestCreateCalendar() {
 	conf := configForTest()
 	locale := newLocale(conf)
 
-	_, err := newCalendar(conf, secretsManagerForTest(), locale)
-	suite.NotNil(err)
+	unsupportedLocale := timetracker.Locale{Country: "fr"}
+	os.Unsetenv("HOB_CALENDAR_APIKEY")
+
+	calendar1, err1 := newCalendar(conf, secretsManagerForTest(), locale, loggerForTest())
+	suite.Nil(err1)
+	suite.IsType(&offlineCalendar{}, calendar1)
+
+	_, err2 := newCalendar(conf, secretsManagerForTest(), unsupportedLocale, loggerForTest())
+	suite.NotNil(err2)
 
 	os.Setenv("HOB_CALENDAR_APIKEY", "xxx")
-	_, err2 := newCalendar(conf, secretsManagerForTest(), locale)
-	suite.Nil(err2)
+	defer os.Unsetenv("HOB_CALENDAR_APIKEY")
+	calendar3, err3 := newCalendar(conf, secretsManagerForTest(), locale, loggerForTest())
+	suite.Nil(err3)
+	suite.IsType(&fallbackCalendar{}, calendar3)
+
+	calendar4, err4 := newCalendar(conf, secretsManagerForTest(), unsupportedLocale, loggerForTest())
+	suite.Nil(err4)
+	suite.IsType(&timetracker.CalendarApi{}, calendar4)
+
+	offlineConf, _ := config.NewStaticConfigSource("hob:\n  calendar:\n    provider: offline\n").Load()
+	calendar5, err5 := newCalendar(offlineConf, secretsManagerForTest(), locale, loggerForTest())
+	suite.Nil(err5)
+	suite.IsType(&offlineCalendar{}, calendar5)
+
+	_, err6 := newCalendar(offlineConf, secretsManagerForTest(), unsupportedLocale, loggerForTest())
+	suite.NotNil(err6)
+
+	invalidConf, _ := config.NewStaticConfigSource("hob:\n  calendar:\n    provider: google\n").Load()
+	_, err7 := newCalendar(invalidConf, secretsManagerForTest(), locale, loggerForTest())
+	suite.NotNil(err7)
 }
 
 func (suite *BootstrapTestSuite) TestNewReportFormatter() {
@@ -79,6 +104,22 @@ func (suite *BootstrapTestSuite) TestNewReportFormatter() {
 	suite.NotNil(formatter1)
 	suite.Nil(err1)
 
+	formatter3, err3 := newReportFormatter(&core.GenerateReportRequest{Format: reportFormatCsv}, loggerForTest())
+	suite.IsType(&csvReportFormatter{}, formatter3)
+	suite.Nil(err3)
+
+	formatter4, err4 := newReportFormatter(&core.GenerateReportRequest{Format: reportFormatPdf}, loggerForTest())
+	suite.IsType(&pdfReportFormatter{}, formatter4)
+	suite.Nil(err4)
+
+	formatter5, err5 := newReportFormatter(&core.GenerateReportRequest{Format: reportFormatHtml}, loggerForTest())
+	suite.IsType(&htmlReportFormatter{}, formatter5)
+	suite.Nil(err5)
+
+	formatter6, err6 := newReportFormatter(&core.GenerateReportRequest{Format: reportFormatJson}, loggerForTest())
+	suite.IsType(&jsonReportFormatter{}, formatter6)
+	suite.Nil(err6)
+
 	formatter2, err2 := newReportFormatter(&core.GenerateReportRequest{Format: core.ReportFormat_NO_FORMAT}, loggerForTest())
 	suite.Nil(formatter2)
 	suite.NotNil(err2)
@@ -97,7 +138,7 @@ func (suite *BootstrapTestSuite) TestNewReportPublisher() {
 			},
 		},
 	}
-	publisher1, err1 := handler.newReportPublisher(request1)
+	publisher1, err1 := handler.newReportPublisher(&reportRequest{GenerateReportRequest: request1})
 	suite.NotNil(publisher1)
 	suite.Nil(err1)
 
@@ -111,7 +152,7 @@ func (suite *BootstrapTestSuite) TestNewReportPublisher() {
 		bucket:   asStringPtr("bucket"),
 		basePath: asStringPtr("/base_path/"),
 	}
-	publisher1_1, err1_1 := handler.newReportPublisher(request1_1)
+	publisher1_1, err1_1 := handler.newReportPublisher(&reportRequest{GenerateReportRequest: request1_1})
 	suite.NotNil(publisher1_1)
 	suite.Nil(err1_1)
 
@@ -122,14 +163,14 @@ func (suite *BootstrapTestSuite) TestNewReportPublisher() {
 			},
 		},
 	}
-	publisher2, err2 := handler.newReportPublisher(request2)
+	publisher2, err2 := handler.newReportPublisher(&reportRequest{GenerateReportRequest: request2})
 	suite.NotNil(publisher2)
 	suite.Nil(err2)
 
 	request3 := &core.GenerateReportRequest{
 		Delivery: &core.ReportDelivery{},
 	}
-	publisher3, err3 := handler.newReportPublisher(request3)
+	publisher3, err3 := handler.newReportPublisher(&reportRequest{GenerateReportRequest: request3})
 	suite.Len(publisher3, 0)
 	suite.NotNil(err3)
 
@@ -140,13 +181,136 @@ func (suite *BootstrapTestSuite) TestNewReportPublisher() {
 			},
 		},
 	}
-	publisher4, err4 := handler.newReportPublisher(request4)
+	publisher4, err4 := handler.newReportPublisher(&reportRequest{GenerateReportRequest: request4})
 	suite.NotNil(publisher4)
 	suite.Len(publisher4, 1)
-	suite.IsType(&timetracker.EMailPublisher{}, publisher4[0])
+	suite.IsType(&mailPublisher{}, publisher4[0])
 	suite.Nil(err4)
-	suite.NotNil(publisher4[0].(*timetracker.EMailPublisher).Source)
-	suite.True(len(publisher4[0].(*timetracker.EMailPublisher).Source) > 0)
+	suite.Equal("user@example.com", publisher4[0].(*mailPublisher).source)
+	suite.Equal(mailRecipients{
+		to:      []string{"user@example.com"},
+		cc:      []string{"hr@example.com"},
+		bcc:     []string{"archive@example.com"},
+		replyTo: "office@example.com",
+	}, publisher4[0].(*mailPublisher).recipients)
+
+	request5 := &reportRequest{GenerateReportRequest: request4, reportOptions: reportOptions{Mail: mailOptions{InlineReport: true}}}
+	publisher5, err5 := handler.newReportPublisher(request5)
+	suite.Nil(err5)
+	suite.Len(publisher5, 1)
+	suite.IsType(&mailPublisher{}, publisher5[0])
+
+	request6 := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{
+		Format: reportFormatJson,
+		Delivery: &core.ReportDelivery{
+			Mail: &core.MailTarget{
+				ToAddresses: []string{"user@example.com"},
+			},
+		},
+	}}
+	publisher6, err6 := handler.newReportPublisher(request6)
+	suite.Nil(err6)
+	suite.Len(publisher6, 1)
+	suite.IsType(&mailPublisher{}, publisher6[0])
+
+	request7 := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{
+		Delivery: &core.ReportDelivery{
+			Mail: &core.MailTarget{
+				ToAddresses: []string{"user@example.com", "user2@example.com"},
+			},
+		},
+	}}
+	publisher7, err7 := handler.newReportPublisher(request7)
+	suite.Nil(err7)
+	suite.Equal([]string{"user@example.com", "user2@example.com"}, publisher7[0].(*mailPublisher).recipients.to)
+
+	request8 := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{
+		Delivery: &core.ReportDelivery{
+			Mail: &core.MailTarget{
+				ToAddresses: []string{"invalid"},
+			},
+		},
+	}}
+	_, err8 := handler.newReportPublisher(request8)
+	suite.NotNil(err8)
+}
+
+func (suite *BootstrapTestSuite) TestNewReportPublisherWithDeliveryTargets() {
+
+	conf, err := config.NewStaticConfigSource(`
+hob:
+  webhook:
+    url: https://example.com/reports
+  sftp:
+    host: sftp.example.com
+    fingerprint: SHA256:xxx
+`).Load()
+	suite.Nil(err)
+	handler := reportGeneratorForTest()
+	handler.conf = conf
+	handler.secretsManager = secrets.NewStaticSecretsManager(map[string]string{})
+
+	request := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{
+		Delivery: &core.ReportDelivery{File: &core.FileTarget{Path: "/tmp/"}},
+	}}
+	publisher1, err1 := handler.newReportPublisher(request)
+	suite.Nil(err1)
+	suite.Len(publisher1, 1)
+
+	request.Targets = []string{"Webhook"}
+	publisher2, err2 := handler.newReportPublisher(request)
+	suite.Nil(err2)
+	suite.Len(publisher2, 2)
+	suite.IsType(&webhookPublisher{}, publisher2[1])
+
+	request.Targets = []string{"ftp"}
+	_, err3 := handler.newReportPublisher(request)
+	suite.NotNil(err3)
+
+	// SFTP config without credentials fails only requests which select this target.
+	request.Targets = []string{"sftp"}
+	_, err4 := handler.newReportPublisher(request)
+	suite.NotNil(err4)
+
+	handler.conf = emptyConfigForTest()
+	request.Targets = []string{"webhook"}
+	_, err5 := handler.newReportPublisher(request)
+	suite.NotNil(err5)
+}
+
+func (suite *BootstrapTestSuite) TestMailTemplatesFromConfig() {
+
+	templates, err := mailTemplatesFromConfig(configForTest())
+	suite.Nil(err)
+	suite.Len(templates, 2)
+	summary := reportSummary{Start: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Period: "2022-01"}
+
+	subject, message, err := templates.forCountry("nl").render(summary)
+	suite.Nil(err)
+	suite.Equal("Urenoverzicht 2022-01", subject)
+	suite.Equal("<p>Bijgaand je urenoverzicht voor 2022-01.</p>", message)
+
+	subject, message, err = templates.forCountry("de").render(summary)
+	suite.Nil(err)
+	suite.Equal("Time Tracking Report 2022-01", subject)
+	suite.Equal(defaultMailBody, message)
+
+	emptyTemplates, err := mailTemplatesFromConfig(emptyConfigForTest())
+	suite.Nil(err)
+	suite.Len(emptyTemplates, 0)
+}
+
+func (suite *BootstrapTestSuite) TestMailRecipients() {
+
+	handler := &ReportGenerator{conf: emptyConfigForTest(), logger: loggerForTest()}
+	recipients, err := handler.mailRecipients([]string{"user@example.com", "invalid"})
+	suite.Nil(err)
+	suite.Equal([]string{"user@example.com"}, recipients.to)
+	suite.Len(recipients.cc, 0)
+	suite.Equal("", recipients.replyTo)
+
+	_, err = handler.mailRecipients([]string{"invalid", "user@"})
+	suite.NotNil(err)
 }
 
 func configForTest() config.Config {
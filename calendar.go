@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/tommzn/go-log"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+// Supported calendar providers, defined by config key hob.calendar.provider.
+const (
+	calendarProviderApi     = "api"
+	calendarProviderOffline = "offline"
+)
+
+// HolidayRule defines a public holiday at a fixed date or at a number of days after Easter Sunday.
+type holidayRule struct {
+	description string
+
+	// Month and day of a holiday at a fixed date.
+	month time.Month
+	day   int
+
+	// EasterOffset is the number of days after Easter Sunday, used if a holiday has no fixed date.
+	easterOffset int
+
+	// SundayOffset moves a holiday at a fixed date by this number of days if it's on a Sunday.
+	sundayOffset int
+}
+
+// FixedHoliday returns a rule for a holiday at the same date each year.
+func fixedHoliday(month time.Month, day int, description string) holidayRule {
+	return holidayRule{description: description, month: month, day: day}
+}
+
+// EasterHoliday returns a rule for a holiday at given number of days after Easter Sunday.
+func easterHoliday(easterOffset int, description string) holidayRule {
+	return holidayRule{description: description, easterOffset: easterOffset}
+}
+
+// HolidayRules contains nationwide public holidays of all countries supported by an offline calendar.
+// Countries are identified by their lower case ISO 3166 code. Swiss National Day is the only federal holiday
+// of CH, all other holidays of CH are defined by cantons and these rules contain holidays observed by most of them.
+var holidayRules = map[string][]holidayRule{
+	"de": {
+		fixedHoliday(time.January, 1, "New Year's Day"),
+		easterHoliday(-2, "Good Friday"),
+		easterHoliday(1, "Easter Monday"),
+		fixedHoliday(time.May, 1, "Labour Day"),
+		easterHoliday(39, "Ascension Day"),
+		easterHoliday(50, "Whit Monday"),
+		fixedHoliday(time.October, 3, "Day of German Unity"),
+		fixedHoliday(time.December, 25, "Christmas Day"),
+		fixedHoliday(time.December, 26, "Second Day of Christmas"),
+	},
+	"nl": {
+		fixedHoliday(time.January, 1, "New Year's Day"),
+		easterHoliday(0, "Easter Sunday"),
+		easterHoliday(1, "Easter Monday"),
+		{description: "King's Day", month: time.April, day: 27, sundayOffset: -1},
+		fixedHoliday(time.May, 5, "Liberation Day"),
+		easterHoliday(39, "Ascension Day"),
+		easterHoliday(49, "Whit Sunday"),
+		easterHoliday(50, "Whit Monday"),
+		fixedHoliday(time.December, 25, "Christmas Day"),
+		fixedHoliday(time.December, 26, "Second Day of Christmas"),
+	},
+	"at": {
+		fixedHoliday(time.January, 1, "New Year's Day"),
+		fixedHoliday(time.January, 6, "Epiphany"),
+		easterHoliday(1, "Easter Monday"),
+		fixedHoliday(time.May, 1, "National Holiday"),
+		easterHoliday(39, "Ascension Day"),
+		easterHoliday(50, "Whit Monday"),
+		easterHoliday(60, "Corpus Christi"),
+		fixedHoliday(time.August, 15, "Assumption Day"),
+		fixedHoliday(time.October, 26, "National Day"),
+		fixedHoliday(time.November, 1, "All Saints' Day"),
+		fixedHoliday(time.December, 8, "Immaculate Conception"),
+		fixedHoliday(time.December, 25, "Christmas Day"),
+		fixedHoliday(time.December, 26, "St. Stephen's Day"),
+	},
+	"ch": {
+		fixedHoliday(time.January, 1, "New Year's Day"),
+		easterHoliday(-2, "Good Friday"),
+		easterHoliday(1, "Easter Monday"),
+		easterHoliday(39, "Ascension Day"),
+		easterHoliday(50, "Whit Monday"),
+		fixedHoliday(time.August, 1, "Swiss National Day"),
+		fixedHoliday(time.December, 25, "Christmas Day"),
+		fixedHoliday(time.December, 26, "St. Stephen's Day"),
+	},
+}
+
+// NewOfflineCalendar returns a calendar which calculates public holidays of given country locally.
+// Returns with an error if a country is not supported.
+func newOfflineCalendar(country string) (*offlineCalendar, error) {
+	rules, ok := holidayRules[strings.ToLower(country)]
+	if !ok {
+		return nil, fmt.Errorf("Holidays of country %s are not supported by offline calendar", country)
+	}
+	return &offlineCalendar{rules: rules}, nil
+}
+
+// OfflineCalendar calculates public holidays from fixed dates and from dates relative to Easter Sunday,
+// without any external service.
+type offlineCalendar struct {
+	rules []holidayRule
+}
+
+// GetHolidays returns all public holidays in given month, ordered by date.
+func (calendar *offlineCalendar) GetHolidays(year, month int) ([]timetracker.Holiday, error) {
+
+	easter := easterSunday(year)
+	holidays := []timetracker.Holiday{}
+	for _, rule := range calendar.rules {
+
+		date := easter.AddDate(0, 0, rule.easterOffset)
+		if rule.month != 0 {
+			date = time.Date(year, rule.month, rule.day, 0, 0, 0, 0, time.UTC)
+			if date.Weekday() == time.Sunday {
+				date = date.AddDate(0, 0, rule.sundayOffset)
+			}
+		}
+		if int(date.Month()) == month {
+			holidays = append(holidays, timetracker.Holiday{
+				Date:        timetracker.Date{Year: date.Year(), Month: int(date.Month()), Day: date.Day()},
+				Description: rule.description,
+			})
+		}
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays, nil
+}
+
+// EasterSunday returns the date of Easter Sunday in given year of the Gregorian calendar,
+// calculated by the anonymous Gregorian algorithm.
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := (19*a + b - b/4 - (b-(b+8)/25+1)/3 + 15) % 30
+	e := (32 + 2*(b%4) + 2*(c/4) - d - c%4) % 7
+	f := d + e - 7*((a+11*d+22*e)/451) + 114
+	return time.Date(year, time.Month(f/31), f%31+1, 0, 0, 0, 0, time.UTC)
+}
+
+// FallbackCalendar obtains holidays from a calendar api and uses an offline calendar if this api is unavailable.
+type fallbackCalendar struct {
+	api     timetracker.Calendar
+	offline timetracker.Calendar
+	logger  log.Logger
+}
+
+// GetHolidays returns holidays of given month from calendar api, or from offline calendar if the api request fails.
+func (calendar *fallbackCalendar) GetHolidays(year, month int) ([]timetracker.Holiday, error) {
+	holidays, err := calendar.api.GetHolidays(year, month)
+	if err != nil {
+		calendar.logger.Errorf("Unable to get holidays from calendar api, use offline calendar. Reason: %s", err)
+		return calendar.offline.GetHolidays(year, month)
+	}
+	return holidays, nil
+}
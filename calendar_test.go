@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type CalendarTestSuite struct {
+	suite.Suite
+}
+
+func TestCalendarTestSuite(t *testing.T) {
+	suite.Run(t, new(CalendarTestSuite))
+}
+
+func (suite *CalendarTestSuite) TestEasterSunday() {
+
+	suite.Equal(time.Date(2019, 4, 21, 0, 0, 0, 0, time.UTC), easterSunday(2019))
+	suite.Equal(time.Date(2022, 4, 17, 0, 0, 0, 0, time.UTC), easterSunday(2022))
+	suite.Equal(time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), easterSunday(2024))
+	suite.Equal(time.Date(2038, 4, 25, 0, 0, 0, 0, time.UTC), easterSunday(2038))
+}
+
+func (suite *CalendarTestSuite) TestGermanHolidays() {
+
+	calendar := suite.calendarForTest("DE")
+	suite.assertHolidays(calendar, 2022, 4, 15, 18)
+	suite.assertHolidays(calendar, 2022, 5, 1, 26)
+	suite.assertHolidays(calendar, 2022, 6, 6)
+	suite.assertHolidays(calendar, 2022, 10, 3)
+	suite.assertHolidays(calendar, 2022, 12, 25, 26)
+	suite.assertHolidays(calendar, 2022, 2)
+
+	holidays, _ := calendar.GetHolidays(2022, 4)
+	suite.Equal("Good Friday", holidays[0].Description)
+}
+
+func (suite *CalendarTestSuite) TestDutchHolidays() {
+
+	calendar := suite.calendarForTest("nl")
+	suite.assertHolidays(calendar, 2022, 4, 17, 18, 27)
+	suite.assertHolidays(calendar, 2022, 5, 5, 26)
+	suite.assertHolidays(calendar, 2022, 6, 5, 6)
+
+	// King's Day is moved to Saturday if April 27th is a Sunday.
+	suite.assertHolidays(calendar, 2025, 4, 20, 21, 26)
+}
+
+func (suite *CalendarTestSuite) TestAustrianHolidays() {
+
+	calendar := suite.calendarForTest("at")
+	suite.assertHolidays(calendar, 2022, 1, 1, 6)
+	suite.assertHolidays(calendar, 2022, 6, 6, 16)
+	suite.assertHolidays(calendar, 2022, 10, 26)
+	suite.assertHolidays(calendar, 2022, 12, 8, 25, 26)
+}
+
+func (suite *CalendarTestSuite) TestSwissHolidays() {
+
+	calendar := suite.calendarForTest("ch")
+	suite.assertHolidays(calendar, 2024, 3, 29)
+	suite.assertHolidays(calendar, 2024, 4, 1)
+	suite.assertHolidays(calendar, 2024, 5, 9, 20)
+	suite.assertHolidays(calendar, 2024, 8, 1)
+}
+
+func (suite *CalendarTestSuite) TestUnsupportedCountry() {
+
+	calendar, err := newOfflineCalendar("fr")
+	suite.Nil(calendar)
+	suite.NotNil(err)
+}
+
+func (suite *CalendarTestSuite) TestFallbackCalendar() {
+
+	apiHolidays := []timetracker.Holiday{{Date: timetracker.Date{Year: 2022, Month: 10, Day: 31}, Description: "Reformation Day"}}
+	api := &calendarMock{holidays: apiHolidays}
+	calendar := &fallbackCalendar{api: api, offline: suite.calendarForTest("de"), logger: loggerForTest()}
+
+	holidays1, err1 := calendar.GetHolidays(2022, 10)
+	suite.Nil(err1)
+	suite.Equal(apiHolidays, holidays1)
+
+	api.err = errors.New("Service unavailable")
+	holidays2, err2 := calendar.GetHolidays(2022, 10)
+	suite.Nil(err2)
+	suite.Len(holidays2, 1)
+	suite.Equal(timetracker.Date{Year: 2022, Month: 10, Day: 3}, holidays2[0].Date)
+}
+
+func (suite *CalendarTestSuite) calendarForTest(country string) *offlineCalendar {
+	calendar, err := newOfflineCalendar(country)
+	suite.Nil(err)
+	return calendar
+}
+
+// AssertHolidays expects holidays at given days, and only at these days, in passed month.
+func (suite *CalendarTestSuite) assertHolidays(calendar timetracker.Calendar, year, month int, days ...int) {
+	holidays, err := calendar.GetHolidays(year, month)
+	suite.Nil(err)
+	holidayDays := []int{}
+	for _, holiday := range holidays {
+		suite.Equal(year, holiday.Year)
+		suite.Equal(month, holiday.Month)
+		holidayDays = append(holidayDays, holiday.Day)
+	}
+	suite.Equal(append([]int{}, days...), holidayDays, "%04d-%02d", year, month)
+}
+
+// CalendarMock returns given holidays or an error.
+type calendarMock struct {
+	holidays []timetracker.Holiday
+	err      error
+}
+
+func (mock *calendarMock) GetHolidays(year, month int) ([]timetracker.Holiday, error) {
+	return mock.holidays, mock.err
+}
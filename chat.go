@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	log "github.com/tommzn/go-log"
+)
+
+// DefaultChatTemplate is used for chat messages if there's no template in config.
+const defaultChatTemplate = `Time Tracking Report {{ .Period }}: {{ duration .TotalWorkingTime }} hours, overtime {{ duration .Overtime }}
+{{- range .Files }}
+{{ .Location }}
+{{- end }}`
+
+// NewChatNotifier returns a notifier which posts a message to given incoming webhook url of a chat,
+// e.g. Slack or Mattermost. Passed locations are prefixes, e.g. a S3 bucket or a local path, report files
+// have been stored at.
+func newChatNotifier(url string, template *texttemplate.Template, locations []string, timeout time.Duration, logger log.Logger) *chatNotifier {
+	return &chatNotifier{
+		url:       url,
+		template:  template,
+		locations: locations,
+		client:    &http.Client{Timeout: timeout},
+		logger:    logger,
+	}
+}
+
+// ChatNotifier sends a short summary of a report, together with links to all report files, to a chat channel.
+// It doesn't send report files, so it has to be used after all publishers which store files.
+type chatNotifier struct {
+	url      string
+	template *texttemplate.Template
+
+	// Locations are prefixes of storage targets, files names are appended to build a link to a file.
+	locations []string
+
+	// Summary of current report.
+	summary reportSummary
+
+	// FileNames are all files which have been published for current report.
+	fileNames []string
+
+	client *http.Client
+	logger log.Logger
+}
+
+// ChatMessage contains all values a chat message template can access.
+type chatMessage struct {
+	reportSummary
+	Files []chatFile
+}
+
+// ChatFile is a single report file and its storage location.
+type chatFile struct {
+	Name, Location string
+}
+
+// WithReport creates a summary of passed report, which is used for the next message.
+func (notifier *chatNotifier) WithReport(report *generatedReport) error {
+	notifier.summary = newReportSummary(report)
+	notifier.fileNames = []string{}
+	return nil
+}
+
+// Send collects all report files, a message is sent after all files have been published.
+func (notifier *chatNotifier) Send(content []byte, fileName string) error {
+	notifier.fileNames = append(notifier.fileNames, fileName)
+	return nil
+}
+
+// Complete posts a message with report summary and links to all published files.
+func (notifier *chatNotifier) Complete() error {
+
+	message := chatMessage{reportSummary: notifier.summary, Files: []chatFile{}}
+	for _, fileName := range notifier.fileNames {
+		for _, location := range notifier.locations {
+			message.Files = append(message.Files, chatFile{Name: fileName, Location: location + fileName})
+		}
+	}
+	text := new(bytes.Buffer)
+	if err := notifier.template.Execute(text, message); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"text": strings.TrimSpace(text.String())})
+	if err != nil {
+		return err
+	}
+	response, err := notifier.client.Post(notifier.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		notifier.logger.Error("Unable to send chat message, reason: ", err)
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("Chat webhook returns with status %s", response.Status)
+	}
+	return nil
+}
+
+// NewChatTemplate parses given chat message template. Function "duration" can be used to format working times.
+func newChatTemplate(template string) (*texttemplate.Template, error) {
+	return texttemplate.New("chat").Funcs(templateFuncs).Parse(template)
+}
+
+// S3Location returns a S3 url for given bucket and path, which has to be completed by an object name.
+// Object keys are generated the same way as by timetracker.S3Publisher.
+func s3Location(bucket, basePath *string) string {
+	location := "s3://"
+	if bucket != nil {
+		location += *bucket
+	}
+	location += "/"
+	if basePath != nil {
+		location += *basePath
+		if !strings.HasSuffix(*basePath, "/") {
+			location += "/"
+		}
+	}
+	return location
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	secrets "github.com/tommzn/go-secrets"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type ChatNotifierTestSuite struct {
+	suite.Suite
+}
+
+func TestChatNotifierTestSuite(t *testing.T) {
+	suite.Run(t, new(ChatNotifierTestSuite))
+}
+
+func (suite *ChatNotifierTestSuite) TestSendMessage() {
+
+	messages := []map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := map[string]string{}
+		suite.Nil(json.NewDecoder(r.Body).Decode(&message))
+		messages = append(messages, message)
+	}))
+	defer server.Close()
+
+	template, err := newChatTemplate(defaultChatTemplate)
+	suite.Nil(err)
+	notifier := newChatNotifier(server.URL, template, []string{"s3://bucket/reports/", "/tmp/"}, time.Second, loggerForTest())
+
+	report := generatedReportForTest()
+	report.Report.(*timetracker.MonthlyReport).Location = timetracker.Locale{DefaultWorkTime: 8 * time.Hour}
+	report.Report.(*timetracker.MonthlyReport).TotalWorkingTime = 170 * time.Hour
+	suite.Nil(notifier.WithReport(report))
+	suite.Nil(notifier.Send([]byte("report"), "report_202201.xlsx"))
+	suite.Nil(notifier.Send([]byte("report"), "report_202201.pdf"))
+	suite.Len(messages, 0)
+
+	suite.Nil(notifier.Complete())
+	suite.Len(messages, 1)
+	suite.Equal("Time Tracking Report 2022-01: 170:00 hours, overtime 02:00\n"+
+		"s3://bucket/reports/report_202201.xlsx\n/tmp/report_202201.xlsx\n"+
+		"s3://bucket/reports/report_202201.pdf\n/tmp/report_202201.pdf", messages[0]["text"])
+}
+
+func (suite *ChatNotifierTestSuite) TestSendMessageFails() {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	template, _ := newChatTemplate("{{ .Period }}")
+	notifier := newChatNotifier(server.URL, template, []string{}, time.Second, loggerForTest())
+	suite.Nil(notifier.WithReport(generatedReportForTest()))
+	suite.NotNil(notifier.Complete())
+}
+
+func (suite *ChatNotifierTestSuite) TestNewChatNotifier() {
+
+	handler := &ReportGenerator{
+		conf:           configForTest(),
+		logger:         loggerForTest(),
+		secretsManager: secrets.NewStaticSecretsManager(map[string]string{"HOB_CHAT_TEAM_WEBHOOK": "https://chat.example.com/hooks/xxx"}),
+	}
+	notifier, err := handler.newChatNotifier("team", []string{"/tmp/"})
+	suite.Nil(err)
+	suite.Equal("https://chat.example.com/hooks/xxx", notifier.url)
+	suite.Equal([]string{"/tmp/"}, notifier.locations)
+
+	_, err = handler.newChatNotifier("invalid", []string{})
+	suite.NotNil(err)
+
+	_, err = handler.newChatNotifier("unknown", []string{})
+	suite.NotNil(err)
+
+	handler.secretsManager = secrets.NewStaticSecretsManager(map[string]string{})
+	_, err = handler.newChatNotifier("team", []string{})
+	suite.NotNil(err)
+}
+
+func (suite *ChatNotifierTestSuite) TestS3Location() {
+
+	suite.Equal("s3://bucket/", s3Location(asStringPtr("bucket"), nil))
+	suite.Equal("s3://bucket/reports/", s3Location(asStringPtr("bucket"), asStringPtr("reports")))
+	suite.Equal("s3://bucket//base_path/", s3Location(asStringPtr("bucket"), asStringPtr("/base_path/")))
+}
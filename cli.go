@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	core "github.com/tommzn/hob-core"
+)
+
+// Exit codes of command line interface.
+const (
+
+	// ExitCodeOk is returned if a report has been generated and delivered.
+	exitCodeOk = 0
+
+	// ExitCodeFailed is returned if report generation or delivery failed.
+	exitCodeFailed = 1
+
+	// ExitCodeUsage is returned for invalid or missing arguments.
+	exitCodeUsage = 2
+
+	// ExitCodeBootstrap is returned if config or other dependencies can't be loaded.
+	exitCodeBootstrap = 3
+)
+
+// Default file name patterns used by command line interface for each report type.
+var defaultNamePatterns = map[string]string{
+	"monthly":   "TimeTrackingReport_200601",
+	"weekly":    "TimeTrackingReport_2006_W{week}",
+	"yearly":    "TimeTrackingReport_2006",
+	"daterange": "TimeTrackingReport_20060102-{end:20060102}",
+}
+
+// Report types which can be generated by command line interface.
+var reportTypeNames = map[string]core.ReportType{
+	"monthly":   core.ReportType_MONTHLY_REPORT,
+	"weekly":    reportTypeWeekly,
+	"yearly":    reportTypeYearly,
+	"daterange": reportTypeDateRange,
+}
+
+// BackfillMonthLayout is used to pass first and last month of a backfill.
+const backfillMonthLayout = "2006-01"
+
+// BootstrapFunc creates a report generator. A config file is used if passed, otherwise config is loaded from S3.
+type bootstrapFunc func(configFile *string) (*ReportGenerator, error)
+
+// GenerateCommand contains all arguments of command "generate".
+type generateCommand struct {
+	reportType  string
+	year        int64
+	month       int64
+	week        int64
+	startDate   string
+	endDate     string
+	formats     string
+	devices     stringList
+	emails      stringList
+	targets     stringList
+	out         string
+	namePattern string
+	configFile  string
+	archive     bool
+}
+
+// BackfillCommand contains all arguments of command "backfill".
+type backfillCommand struct {
+	generateCommand
+	from        string
+	to          string
+	concurrency int
+	force       bool
+}
+
+// StringList is a flag which can be passed multiple times.
+type stringList []string
+
+// String returns all values as comma separated list.
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+// Set appends a value to the list.
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// RunGenerateCommand generates a report for passed command line arguments, e.g.
+//
+//	generate --year 2026 --month 9 --format excel --device Device01 --out ./reports
+//
+// A report is written to a local directory and delivered to all targets defined in config.
+// Returned value is an exit code.
+func runGenerateCommand(args []string, stdout, stderr io.Writer, bootstrap bootstrapFunc) int {
+
+	command, err := parseGenerateCommand(args, stderr)
+	if err != nil {
+		return usageExitCode(err, stderr)
+	}
+	request, err := command.reportRequest()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCodeUsage
+	}
+	handler, exitCode := command.prepare(bootstrap, stderr)
+	if handler == nil {
+		return exitCode
+	}
+	defer handler.logger.Flush()
+
+	if err := handler.processRequest(context.Background(), request); err != nil {
+		fmt.Fprintln(stderr, "Unable to generate report, reason:", err)
+		if err == errNoReportDelivery {
+			return exitCodeUsage
+		}
+		return exitCodeFailed
+	}
+	fmt.Fprintln(stdout, "Report generated.")
+	return exitCodeOk
+}
+
+// RunBackfillCommand generates monthly reports for a range of months, e.g.
+//
+//	backfill --from 2026-01 --to 2026-06 --concurrency 2 --out ./reports
+//
+// Months with unchanged reports are skipped, unless --force is set. A summary table is written to stdout
+// and returned exit code indicates if there's at least one failed month.
+func runBackfillCommand(args []string, stdout, stderr io.Writer, bootstrap bootstrapFunc) int {
+
+	command, err := parseBackfillCommand(args, stderr)
+	if err != nil {
+		return usageExitCode(err, stderr)
+	}
+	request, err := command.reportRequest()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCodeUsage
+	}
+	start, end, err := command.monthRange()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCodeUsage
+	}
+	handler, exitCode := command.prepare(bootstrap, stderr)
+	if handler == nil {
+		return exitCode
+	}
+	defer handler.logger.Flush()
+
+	if _, err := handler.newReportPublisher(request); err != nil {
+		fmt.Fprintln(stderr, "Unable to deliver reports, reason:", err)
+		if err == errNoReportDelivery {
+			return exitCodeUsage
+		}
+		return exitCodeFailed
+	}
+
+	results := handler.Backfill(context.Background(), request, start, end, command.concurrency, command.force)
+	writeBackfillSummary(stdout, results)
+	for _, result := range results {
+		if result.Status == backfillStatusFailed {
+			return exitCodeFailed
+		}
+	}
+	return exitCodeOk
+}
+
+// UsageExitCode prints given error of parsing command line arguments and returns an exit code for it.
+func usageExitCode(err error, stderr io.Writer) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitCodeOk
+	}
+	fmt.Fprintln(stderr, err)
+	return exitCodeUsage
+}
+
+// Prepare creates an output directory, if defined, and a report generator. Returns an exit code if one of these steps fails.
+func (command *generateCommand) prepare(bootstrap bootstrapFunc, stderr io.Writer) (*ReportGenerator, int) {
+
+	if command.out != "" {
+		if err := os.MkdirAll(command.out, 0755); err != nil {
+			fmt.Fprintln(stderr, err)
+			return nil, exitCodeFailed
+		}
+	}
+
+	var configFile *string
+	if command.configFile != "" {
+		configFile = &command.configFile
+	}
+	handler, err := bootstrap(configFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "Unable to load config, reason:", err)
+		return nil, exitCodeBootstrap
+	}
+	return handler, exitCodeOk
+}
+
+// ParseGenerateCommand reads all arguments of command "generate".
+func parseGenerateCommand(args []string, output io.Writer) (*generateCommand, error) {
+
+	command := &generateCommand{}
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&command.reportType, "type", "monthly", "Report type, monthly, weekly, yearly or daterange.")
+	flags.Int64Var(&command.year, "year", 0, "Year of a report, previous period is used by default. Required for a month or week.")
+	flags.Int64Var(&command.month, "month", 0, "Month of a monthly report, required if a year is passed.")
+	flags.Int64Var(&command.week, "week", 0, "ISO week of a weekly report, required if a year is passed.")
+	flags.StringVar(&command.startDate, "start", "", "First day, YYYY-MM-DD, of a date range report.")
+	flags.StringVar(&command.endDate, "end", "", "Last day, YYYY-MM-DD, of a date range report.")
+	command.registerDeliveryFlags(flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// ParseBackfillCommand reads all arguments of command "backfill".
+func parseBackfillCommand(args []string, output io.Writer) (*backfillCommand, error) {
+
+	command := &backfillCommand{generateCommand: generateCommand{reportType: "monthly"}}
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&command.from, "from", "", "First month, YYYY-MM, reports should be generated for.")
+	flags.StringVar(&command.to, "to", "", "Last month, YYYY-MM, reports should be generated for.")
+	flags.IntVar(&command.concurrency, "concurrency", 2, "Max number of months which are generated at the same time.")
+	flags.BoolVar(&command.force, "force", false, "Deliver all reports, even if they're unchanged.")
+	command.registerDeliveryFlags(flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// RegisterDeliveryFlags adds all flags for report formats, devices and delivery targets to given flag set.
+func (command *generateCommand) registerDeliveryFlags(flags *flag.FlagSet) {
+	flags.StringVar(&command.formats, "format", "excel", "Comma separated list of report formats, excel, csv, pdf, html or json.")
+	flags.Var(&command.devices, "device", "Device a report is generated for, can be passed multiple times. Devices from config are used by default.")
+	flags.Var(&command.emails, "email", "Email address a report is sent to, can be passed multiple times.")
+	flags.Var(&command.targets, "target", "Delivery target defined in config, webhook or sftp, can be passed multiple times.")
+	flags.StringVar(&command.out, "out", "", "Local directory reports are written to.")
+	flags.StringVar(&command.namePattern, "name", "", "File name pattern, time layouts are formatted with start of a report period.")
+	flags.StringVar(&command.configFile, "config", "", "Local config file, config is loaded from S3 by default.")
+	flags.BoolVar(&command.archive, "archive", false, "Pack all report files into a ZIP archive.")
+}
+
+// ParseFlags parses passed arguments and fails if there're arguments which are not a flag.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("Unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	return nil
+}
+
+// MonthRange returns first and last month of a backfill.
+func (command *backfillCommand) monthRange() (time.Time, time.Time, error) {
+
+	start, err := time.Parse(backfillMonthLayout, command.from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid first month: %s", command.from)
+	}
+	end, err := time.Parse(backfillMonthLayout, command.to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid last month: %s", command.to)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("First month %s has to be before last month %s", command.from, command.to)
+	}
+	return start, end, nil
+}
+
+// ReportRequest creates a report request from command line arguments.
+func (command *generateCommand) reportRequest() (*reportRequest, error) {
+
+	reportType, ok := reportTypeNames[strings.ToLower(command.reportType)]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported report type: %s", command.reportType)
+	}
+	if command.month < 0 || command.month > 12 {
+		return nil, fmt.Errorf("Invalid month: %d", command.month)
+	}
+	if command.week < 0 || command.week > 53 {
+		return nil, fmt.Errorf("Invalid week: %d", command.week)
+	}
+	if command.year == 0 && (command.month != 0 || command.week != 0) {
+		return nil, errors.New("Year is required if a month or week is passed")
+	}
+	if command.year != 0 && reportType == core.ReportType_MONTHLY_REPORT && command.month == 0 {
+		return nil, errors.New("Month is required if a year is passed for a monthly report")
+	}
+	if command.year != 0 && reportType == reportTypeWeekly && command.week == 0 {
+		return nil, errors.New("Week is required if a year is passed for a weekly report")
+	}
+	if reportType == reportTypeDateRange && (command.startDate == "" || command.endDate == "") {
+		return nil, errors.New("Start and end date are required for a date range report")
+	}
+
+	formats := []string{}
+	for _, format := range strings.Split(command.formats, ",") {
+		if format = strings.TrimSpace(format); format != "" {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, errors.New("No report format defined")
+	}
+	format, ok := reportFormatNames[strings.ToLower(formats[0])]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported report format: %s", formats[0])
+	}
+
+	namePattern := command.namePattern
+	if namePattern == "" {
+		namePattern = defaultNamePatterns[strings.ToLower(command.reportType)]
+	}
+
+	request := &reportRequest{
+		GenerateReportRequest: &core.GenerateReportRequest{
+			Type:        reportType,
+			Format:      format,
+			Year:        command.year,
+			Month:       command.month,
+			NamePattern: namePattern,
+			DeviceIds:   command.devices,
+			Delivery:    &core.ReportDelivery{},
+		},
+		reportOptions: reportOptions{
+			Week:      command.week,
+			StartDate: command.startDate,
+			EndDate:   command.endDate,
+			Formats:   formats[1:],
+			Archive:   command.archive,
+			Targets:   command.targets,
+		},
+	}
+	if command.out != "" {
+		request.Delivery.File = &core.FileTarget{Path: filepath.Clean(command.out) + string(filepath.Separator)}
+	}
+	if len(command.emails) > 0 {
+		request.Delivery.Mail = &core.MailTarget{ToAddresses: command.emails}
+	}
+	return request, nil
+}
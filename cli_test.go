@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	core "github.com/tommzn/hob-core"
+)
+
+type CliTestSuite struct {
+	suite.Suite
+}
+
+func TestCliTestSuite(t *testing.T) {
+	suite.Run(t, new(CliTestSuite))
+}
+
+func (suite *CliTestSuite) TestGenerateReport() {
+
+	out := filepath.Join(suite.T().TempDir(), "reports")
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	exitCode := runGenerateCommand([]string{"--year", "2022", "--month", "1", "--format", "excel,csv", "--device", "Device01", "--out", out}, stdout, stderr, bootstrapForTest)
+	suite.Equal(exitCodeOk, exitCode, stderr.String())
+	suite.FileExists(filepath.Join(out, "TimeTrackingReport_202201.xlsx"))
+	suite.FileExists(filepath.Join(out, "TimeTrackingReport_202201.csv"))
+	suite.Contains(stdout.String(), "Report generated.")
+}
+
+func (suite *CliTestSuite) TestExitCodes() {
+
+	out := suite.T().TempDir()
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	suite.Equal(exitCodeUsage, runGenerateCommand([]string{"--month", "13", "--out", out}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runGenerateCommand([]string{"--format", "docx", "--out", out}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runGenerateCommand([]string{"--unknown"}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runGenerateCommand([]string{"--year", "2022", "--month", "1"}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runGenerateCommand([]string{"--month", "9", "--out", out}, stdout, stderr, bootstrapForTest))
+
+	failingBootstrap := func(configFile *string) (*ReportGenerator, error) {
+		return nil, errors.New("No config")
+	}
+	suite.Equal(exitCodeBootstrap, runGenerateCommand([]string{"--out", out}, stdout, stderr, failingBootstrap))
+
+	suite.Equal(exitCodeFailed, runGenerateCommand([]string{"--type", "daterange", "--start", "2022-02-01", "--end", "2022-01-01", "--out", out}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeFailed, runGenerateCommand([]string{"--out", filepath.Join(out, "missing", "\x00")}, stdout, stderr, bootstrapForTest))
+}
+
+func (suite *CliTestSuite) TestBootstrapWithConfigFile() {
+
+	var passedConfigFile *string
+	bootstrap := func(configFile *string) (*ReportGenerator, error) {
+		passedConfigFile = configFile
+		return reportGeneratorForTest(), nil
+	}
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	runGenerateCommand([]string{"--config", "fixtures/testconfig.yml", "--out", suite.T().TempDir()}, stdout, stderr, bootstrap)
+	suite.NotNil(passedConfigFile)
+	suite.Equal("fixtures/testconfig.yml", *passedConfigFile)
+
+	conf, err := loadConfig(passedConfigFile)
+	suite.Nil(err)
+	suite.Len(deviceIds(conf), len(deviceIds(configForTest())))
+}
+
+func (suite *CliTestSuite) TestReportRequest() {
+
+	command, err := parseGenerateCommand([]string{"--type", "weekly", "--year", "2026", "--week", "41", "--format", "pdf, json", "--email", "user@example.com", "--target", "webhook", "--archive"}, new(bytes.Buffer))
+	suite.Nil(err)
+	request, err := command.reportRequest()
+	suite.Nil(err)
+	suite.Equal(reportTypeWeekly, request.Type)
+	suite.Equal(reportFormatPdf, request.Format)
+	suite.Equal([]string{"json"}, request.Formats)
+	suite.Equal(int64(2026), request.Year)
+	suite.Equal(int64(41), request.Week)
+	suite.Equal("TimeTrackingReport_2006_W{week}", request.NamePattern)
+	suite.True(request.Archive)
+	suite.Nil(request.Delivery.File)
+	suite.Equal([]string{"user@example.com"}, request.Delivery.Mail.ToAddresses)
+	suite.Equal([]string{"webhook"}, request.Targets)
+
+	command, err = parseGenerateCommand([]string{"--type", "daterange", "--start", "2026-09-01"}, new(bytes.Buffer))
+	suite.Nil(err)
+	_, err = command.reportRequest()
+	suite.NotNil(err)
+
+	command, err = parseGenerateCommand([]string{"--out", "reports", "--name", "Report_200601"}, new(bytes.Buffer))
+	suite.Nil(err)
+	request, err = command.reportRequest()
+	suite.Nil(err)
+	suite.Equal(core.ReportType_MONTHLY_REPORT, request.Type)
+	suite.Equal(core.ReportFormat_EXCEL, request.Format)
+	suite.Equal("Report_200601", request.NamePattern)
+	suite.Equal("reports"+string(filepath.Separator), request.Delivery.File.Path)
+
+	_, err = parseGenerateCommand([]string{"2026"}, new(bytes.Buffer))
+	suite.NotNil(err)
+
+	command, err = parseGenerateCommand([]string{"--month", "9"}, new(bytes.Buffer))
+	suite.Nil(err)
+	_, err = command.reportRequest()
+	suite.NotNil(err)
+
+	command, err = parseGenerateCommand([]string{"--type", "weekly", "--week", "41"}, new(bytes.Buffer))
+	suite.Nil(err)
+	_, err = command.reportRequest()
+	suite.NotNil(err)
+
+	command, err = parseGenerateCommand([]string{"--year", "2026"}, new(bytes.Buffer))
+	suite.Nil(err)
+	_, err = command.reportRequest()
+	suite.NotNil(err)
+
+	command, err = parseGenerateCommand([]string{"--type", "weekly", "--year", "2026"}, new(bytes.Buffer))
+	suite.Nil(err)
+	_, err = command.reportRequest()
+	suite.NotNil(err)
+
+	command, err = parseGenerateCommand([]string{"--type", "yearly", "--year", "2026"}, new(bytes.Buffer))
+	suite.Nil(err)
+	request, err = command.reportRequest()
+	suite.Nil(err)
+	suite.Equal(int64(2026), request.Year)
+}
+
+func (suite *CliTestSuite) TestBackfill() {
+
+	out := suite.T().TempDir()
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	exitCode := runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-03", "--format", "csv", "--device", "Device01", "--out", out}, stdout, stderr, bootstrapForTest)
+	suite.Equal(exitCodeOk, exitCode, stderr.String())
+	suite.Contains(stdout.String(), "3 generated, 0 skipped, 0 failed")
+	suite.FileExists(filepath.Join(out, "TimeTrackingReport_202202.csv"))
+
+	stdout.Reset()
+	suite.Equal(exitCodeOk, runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-03", "--format", "csv", "--device", "Device01", "--out", out}, stdout, stderr, bootstrapForTest))
+	suite.Contains(stdout.String(), "0 generated, 3 skipped, 0 failed")
+
+	stdout.Reset()
+	suite.Equal(exitCodeOk, runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-01", "--format", "csv", "--out", out, "--force"}, stdout, stderr, bootstrapForTest))
+	suite.Contains(stdout.String(), "1 generated, 0 skipped, 0 failed")
+}
+
+func (suite *CliTestSuite) TestBackfillExitCodes() {
+
+	out := suite.T().TempDir()
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	suite.Equal(exitCodeUsage, runBackfillCommand([]string{"--from", "2022-13", "--to", "2022-03", "--out", out}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runBackfillCommand([]string{"--from", "2022-03", "--to", "2022-01", "--out", out}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-03"}, stdout, stderr, bootstrapForTest))
+	suite.Equal(exitCodeUsage, runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-03", "--format", "docx", "--out", out}, stdout, stderr, bootstrapForTest))
+
+	failingBootstrap := func(configFile *string) (*ReportGenerator, error) {
+		return nil, errors.New("No config")
+	}
+	suite.Equal(exitCodeBootstrap, runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-03", "--out", out}, stdout, stderr, failingBootstrap))
+
+	stdout.Reset()
+	suite.Equal(exitCodeFailed, runBackfillCommand([]string{"--from", "2022-01", "--to", "2022-02", "--format", "csv,docx", "--out", out}, stdout, stderr, bootstrapForTest))
+}
+
+func bootstrapForTest(configFile *string) (*ReportGenerator, error) {
+	return reportGeneratorForTest(), nil
+}
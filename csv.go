@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/tommzn/go-log"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+// DecimalCommaCountries is a list of countries, ISO 3166-1 codes, which are using a comma as decimal separator.
+// CSV files for these countries will use a semicolon as delimiter.
+var decimalCommaCountries = map[string]bool{
+	"AT": true,
+	"BE": true,
+	"DE": true,
+	"DK": true,
+	"ES": true,
+	"FR": true,
+	"IT": true,
+	"NL": true,
+	"PL": true,
+}
+
+// NewCsvReportFormatter returns a new formatter to generate CSV files for reports.
+func newCsvReportFormatter(logger log.Logger) *csvReportFormatter {
+	return &csvReportFormatter{
+		holidays:   make(map[timetracker.Date]timetracker.Holiday),
+		timeFormat: "15:04",
+		logger:     logger,
+	}
+}
+
+// CsvReportFormatter generates CSV files with one row per day. Delimiter, decimal separator
+// and date format depend on locale of a report.
+type csvReportFormatter struct {
+
+	// Holidays is a list of public holidays, indexed by date.
+	holidays map[timetracker.Date]timetracker.Holiday
+
+	// TimeFormat defines output format for timestamps of time tracking records.
+	timeFormat string
+
+	logger log.Logger
+}
+
+// CsvLocale contains all locale dependent settings to write a CSV file.
+type csvLocale struct {
+	delimiter        rune
+	decimalSeparator string
+	dateFormat       string
+	timezone         *time.Location
+}
+
+// FileExtension returns file extension for CSV files: csv.
+func (formatter *csvReportFormatter) FileExtension() string {
+	return ".csv"
+}
+
+// WithHolidays will assign give list of holidays for output formatting.
+func (formatter *csvReportFormatter) WithHolidays(holidays []timetracker.Holiday) {
+	formatter.holidays = make(map[timetracker.Date]timetracker.Holiday)
+	for _, holiday := range holidays {
+		formatter.holidays[holiday.Date] = holiday
+	}
+}
+
+// WriteMonthlyReportToFile will generate a report output an writes it to given file.
+func (formatter *csvReportFormatter) WriteMonthlyReportToFile(report *timetracker.MonthlyReport, filename string) error {
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// WriteMonthlyReportToBuffer returns a buffer with a row for each day of given monthly report.
+func (formatter *csvReportFormatter) WriteMonthlyReportToBuffer(report *timetracker.MonthlyReport) (*bytes.Buffer, error) {
+	return formatter.writeDays(daysInMonth(report), report.Location)
+}
+
+// WritePeriodReportToBuffer returns a buffer with a row for each day of given report.
+func (formatter *csvReportFormatter) WritePeriodReportToBuffer(report *periodReport) (*bytes.Buffer, error) {
+	return formatter.writeDays(report.Days, report.Location)
+}
+
+// WriteYearlyReportToBuffer returns a buffer with a row for each month of given yearly report.
+func (formatter *csvReportFormatter) WriteYearlyReportToBuffer(report *yearlyReport) (*bytes.Buffer, error) {
+
+	locale := formatter.csvLocale(report.Location)
+	rows := [][]string{{"Month", "Workdays", "WorkingTime", "ExpectedWorkingTime", "Overtime", "OvertimeBalance"}}
+	for _, month := range report.Months {
+		rows = append(rows, []string{
+			fmt.Sprintf("%04d-%02d", report.Year, month.Month),
+			strconv.Itoa(month.Workdays),
+			locale.formatHours(month.WorkingTime),
+			locale.formatHours(month.ExpectedWorkingTime),
+			locale.formatHours(month.Overtime),
+			locale.formatHours(month.OvertimeBalance),
+		})
+	}
+	return writeCsv(rows, locale.delimiter)
+}
+
+// WriteDays generates a CSV file with a headline and a row for each of passed days.
+func (formatter *csvReportFormatter) writeDays(days []timetracker.Day, location timetracker.Locale) (*bytes.Buffer, error) {
+
+	locale := formatter.csvLocale(location)
+	rows := [][]string{{"Date", "Type", "Start", "End", "BreakTime", "WorkingTime", "Holiday"}}
+	for _, day := range days {
+		start, end := "", ""
+		if len(day.Events) > 0 {
+			start = locale.formatTime(day.Events[0].Timestamp, formatter.timeFormat)
+		}
+		if len(day.Events) > 1 {
+			end = locale.formatTime(day.Events[len(day.Events)-1].Timestamp, formatter.timeFormat)
+		}
+		holiday := ""
+		if publicHoliday, ok := formatter.holidays[day.Date]; ok {
+			holiday = publicHoliday.Description
+		}
+		rows = append(rows, []string{
+			day.Date.Format(locale.dateFormat),
+			string(day.Type),
+			start,
+			end,
+			locale.formatHours(day.BreakTime),
+			locale.formatHours(day.WorkingTime),
+			holiday,
+		})
+	}
+	return writeCsv(rows, locale.delimiter)
+}
+
+// CsvLocale returns delimiter, decimal separator, date format and timezone for given locale.
+func (formatter *csvReportFormatter) csvLocale(location timetracker.Locale) csvLocale {
+
+	locale := csvLocale{delimiter: ',', decimalSeparator: ".", dateFormat: "2006-01-02"}
+	if decimalCommaCountries[strings.ToUpper(location.Country)] {
+		locale.delimiter = ';'
+		locale.decimalSeparator = ","
+	}
+	if location.DateFormat != nil {
+		locale.dateFormat = *location.DateFormat
+	}
+	timezone, err := loadTimezone(location)
+	if err != nil {
+		formatter.logger.Error("Unable to load location, reason: ", err)
+	}
+	locale.timezone = timezone
+	return locale
+}
+
+// FormatHours returns given duration as decimal hours, e.g. 7.50, using decimal separator of current locale.
+func (locale csvLocale) formatHours(d time.Duration) string {
+	return strings.Replace(strconv.FormatFloat(d.Round(time.Minute).Hours(), 'f', 2, 64), ".", locale.decimalSeparator, 1)
+}
+
+// FormatTime converts given timestamp to timezone of current locale and applies passed layout.
+func (locale csvLocale) formatTime(t time.Time, layout string) string {
+	if locale.timezone != nil {
+		t = t.In(locale.timezone)
+	}
+	return t.Format(layout)
+}
+
+// WriteCsv writes all rows with given delimiter to a buffer.
+func writeCsv(rows [][]string, delimiter rune) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	writer.Comma = delimiter
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
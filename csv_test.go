@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type CsvReportFormatterTestSuite struct {
+	suite.Suite
+}
+
+func TestCsvReportFormatterTestSuite(t *testing.T) {
+	suite.Run(t, new(CsvReportFormatterTestSuite))
+}
+
+func (suite *CsvReportFormatterTestSuite) TestWriteMonthlyReport() {
+
+	formatter := newCsvReportFormatter(loggerForTest())
+	formatter.WithHolidays([]timetracker.Holiday{
+		{Date: timetracker.Date{Year: 2022, Month: 1, Day: 1}, Description: "New Year"},
+	})
+	report := &timetracker.MonthlyReport{
+		Year:     2022,
+		Month:    1,
+		Location: timetracker.Locale{Country: "de", DateFormat: asStringPtr("02.01.2006"), Timezone: asStringPtr("Europe/Berlin")},
+		Days: []timetracker.Day{
+			{
+				Date:        timetracker.Date{Year: 2022, Month: 1, Day: 3},
+				Type:        timetracker.WORKDAY,
+				WorkingTime: 7*time.Hour + 45*time.Minute,
+				BreakTime:   45 * time.Minute,
+				Events: []timetracker.TimeTrackingRecord{
+					{Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 7, 0, 0, 0, time.UTC)},
+					{Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 15, 30, 0, 0, time.UTC)},
+				},
+			},
+		},
+		TotalWorkingTime: 7*time.Hour + 45*time.Minute,
+	}
+
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	suite.Nil(err)
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Len(rows, 32)
+	suite.Equal("Date;Type;Start;End;BreakTime;WorkingTime;Holiday", rows[0])
+	suite.Equal("01.01.2022;workday;;;0,00;0,00;New Year", rows[1])
+	suite.Equal("03.01.2022;workday;08:00;16:30;0,75;7,75;", rows[3])
+	suite.Equal(".csv", formatter.FileExtension())
+}
+
+func (suite *CsvReportFormatterTestSuite) TestWritePeriodReport() {
+
+	formatter := newCsvReportFormatter(loggerForTest())
+	report := periodReportForTest()
+	report.Location = timetracker.Locale{Country: "US"}
+
+	buf, err := formatter.WritePeriodReportToBuffer(report)
+	suite.Nil(err)
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Len(rows, 4)
+	suite.Equal("Date,Type,Start,End,BreakTime,WorkingTime,Holiday", rows[0])
+	suite.Equal("2021-12-31,workday,08:00,16:30,0.50,8.00,", rows[1])
+	suite.Equal("2022-01-02,vacation,,,0.00,0.00,", rows[3])
+}
+
+func (suite *CsvReportFormatterTestSuite) TestWriteYearlyReport() {
+
+	formatter := newCsvReportFormatter(loggerForTest())
+	report := &yearlyReport{
+		Year:     2022,
+		Location: timetracker.Locale{Country: "NL"},
+		Months: []monthlySummary{
+			{Month: 1, Workdays: 21, WorkingTime: 170 * time.Hour, ExpectedWorkingTime: 168 * time.Hour, Overtime: 2 * time.Hour, OvertimeBalance: 2 * time.Hour},
+			{Month: 2, Workdays: 20, WorkingTime: 156*time.Hour + 30*time.Minute, ExpectedWorkingTime: 160 * time.Hour, Overtime: -3*time.Hour - 30*time.Minute, OvertimeBalance: -90 * time.Minute},
+		},
+	}
+
+	buf, err := formatter.WriteYearlyReportToBuffer(report)
+	suite.Nil(err)
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Len(rows, 3)
+	suite.Equal("2022-02;20;156,50;160,00;-3,50;-1,50", rows[2])
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	log "github.com/tommzn/go-log"
+	timetracker "github.com/tommzn/hob-timetracker"
+	"github.com/xuri/excelize/v2"
+)
+
+// NewExcelReportFormatter returns an Excel formatter which is able to write monthly reports and reports for arbitrary time ranges.
+func newExcelReportFormatter(logger log.Logger) *excelReportFormatter {
+	return &excelReportFormatter{
+		ExcelReportFormatter: timetracker.NewExcelReportFormatter(logger),
+		holidays:             make(map[timetracker.Date]timetracker.Holiday),
+		dateFormat:           "2006-01-02",
+		timeFormat:           "15:04",
+		logger:               logger,
+	}
+}
+
+// ExcelReportFormatter extends the Excel formatter from time tracker package with support of additional report types.
+type excelReportFormatter struct {
+	*timetracker.ExcelReportFormatter
+
+	// Holidays is a list of public holidays, indexed by date.
+	holidays map[timetracker.Date]timetracker.Holiday
+
+	// DateFormat defines the format a day should be printed in the output.
+	dateFormat string
+
+	// TimeFormat defines output format for timestamps of time tracking records.
+	timeFormat string
+
+	// Timezone is used to convert timestamps from time tracking records, captured in UTC, to local time.
+	timezone *time.Location
+
+	logger log.Logger
+}
+
+// WithHolidays will assign give list of holidays for output formatting.
+func (formatter *excelReportFormatter) WithHolidays(holidays []timetracker.Holiday) {
+	formatter.ExcelReportFormatter.WithHolidays(holidays)
+	formatter.holidays = make(map[timetracker.Date]timetracker.Holiday)
+	for _, holiday := range holidays {
+		formatter.holidays[holiday.Date] = holiday
+	}
+}
+
+// WritePeriodReportToBuffer generates an Excel file with all days of given report and returns it as buffer.
+func (formatter *excelReportFormatter) WritePeriodReportToBuffer(report *periodReport) (*bytes.Buffer, error) {
+
+	formatter.applyLocale(report.Location)
+
+	sheetName := report.Start.Format("2006-01-02")
+	xls := newExcelFile(sheetName)
+	headlineStyleId, err := xls.NewStyle(&excelize.Style{
+		Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 2}},
+		Font:   &excelize.Font{Bold: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	highlightStyleId, err := xls.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#FED7DE"}, Pattern: 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, headline := range []string{"Date", "Start", "End", "WorkingTime", "BreakTime", "Comment"} {
+		xls.SetCellValue(sheetName, cellId(idx, 1), headline)
+	}
+	xls.SetCellStyle(sheetName, cellId(0, 1), cellId(5, 1), headlineStyleId)
+
+	row := 2
+	for _, day := range report.Days {
+		xls.SetCellValue(sheetName, cellId(0, row), day.Date.Format(formatter.dateFormat))
+		if len(day.Events) > 0 {
+			xls.SetCellValue(sheetName, cellId(1, row), formatter.formatTime(day.Events[0].Timestamp))
+		}
+		if len(day.Events) > 1 {
+			xls.SetCellValue(sheetName, cellId(2, row), formatter.formatTime(day.Events[len(day.Events)-1].Timestamp))
+		}
+		xls.SetCellValue(sheetName, cellId(3, row), formatDuration(day.WorkingTime))
+		xls.SetCellValue(sheetName, cellId(4, row), formatDuration(day.BreakTime))
+		if comment := formatter.comment(day); comment != "" {
+			xls.SetCellValue(sheetName, cellId(5, row), comment)
+			xls.SetCellStyle(sheetName, cellId(0, row), cellId(5, row), highlightStyleId)
+		}
+		row++
+	}
+	xls.SetCellValue(sheetName, cellId(3, row), formatDuration(report.TotalWorkingTime))
+	xls.SetColWidth(sheetName, "A", "E", 12)
+	xls.SetColWidth(sheetName, "F", "F", 30)
+	return xls.WriteToBuffer()
+}
+
+// WriteYearlyReportToBuffer generates an Excel file with a summary for each month of given report and returns it as buffer.
+func (formatter *excelReportFormatter) WriteYearlyReportToBuffer(report *yearlyReport) (*bytes.Buffer, error) {
+
+	sheetName := fmt.Sprintf("%04d", report.Year)
+	xls := newExcelFile(sheetName)
+	headlineStyleId, err := xls.NewStyle(&excelize.Style{
+		Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 2}},
+		Font:   &excelize.Font{Bold: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, headline := range []string{"Month", "Workdays", "WorkingTime", "ExpectedWorkingTime", "Overtime", "OvertimeBalance"} {
+		xls.SetCellValue(sheetName, cellId(idx, 1), headline)
+	}
+	xls.SetCellStyle(sheetName, cellId(0, 1), cellId(5, 1), headlineStyleId)
+
+	row := 2
+	for _, month := range report.Months {
+		xls.SetCellValue(sheetName, cellId(0, row), fmt.Sprintf("%04d-%02d", report.Year, month.Month))
+		xls.SetCellValue(sheetName, cellId(1, row), month.Workdays)
+		xls.SetCellValue(sheetName, cellId(2, row), formatDuration(month.WorkingTime))
+		xls.SetCellValue(sheetName, cellId(3, row), formatDuration(month.ExpectedWorkingTime))
+		xls.SetCellValue(sheetName, cellId(4, row), formatDuration(month.Overtime))
+		xls.SetCellValue(sheetName, cellId(5, row), formatDuration(month.OvertimeBalance))
+		row++
+	}
+	xls.SetCellValue(sheetName, cellId(2, row), formatDuration(report.TotalWorkingTime))
+	xls.SetCellValue(sheetName, cellId(3, row), formatDuration(report.ExpectedWorkingTime))
+	xls.SetCellValue(sheetName, cellId(4, row), formatDuration(report.Overtime))
+	xls.SetCellStyle(sheetName, cellId(0, row), cellId(5, row), headlineStyleId)
+	xls.SetColWidth(sheetName, "A", "F", 20)
+	return xls.WriteToBuffer()
+}
+
+// Comment returns a holiday description, or vacation/illness for passed day.
+func (formatter *excelReportFormatter) comment(day timetracker.Day) string {
+	if holiday, ok := formatter.holidays[day.Date]; ok {
+		return holiday.Description
+	}
+	switch day.Type {
+	case timetracker.VACATION:
+		return "Vacation"
+	case timetracker.ILLNESS:
+		return "Illness"
+	default:
+		return ""
+	}
+}
+
+// ApplyLocale will use date format and timezone of given locale, if defined.
+func (formatter *excelReportFormatter) applyLocale(locale timetracker.Locale) {
+	if locale.DateFormat != nil {
+		formatter.dateFormat = *locale.DateFormat
+	}
+	timezone, err := loadTimezone(locale)
+	if err != nil {
+		formatter.logger.Error("Unable to load location, reason: ", err)
+	}
+	formatter.timezone = timezone
+}
+
+// FormatTime converts given timestamp to formatter timezone and applies default time format.
+func (formatter *excelReportFormatter) formatTime(t time.Time) string {
+	if formatter.timezone != nil {
+		t = t.In(formatter.timezone)
+	}
+	return t.Format(formatter.timeFormat)
+}
+
+// NewExcelFile creates a new, empty Excel file with one sheet using passed sheet name.
+func newExcelFile(sheetName string) *excelize.File {
+	xls := excelize.NewFile()
+	xls.SetSheetName(xls.GetSheetName(0), sheetName)
+	xls.SetActiveSheet(xls.GetSheetIndex(sheetName))
+	return xls
+}
+
+// CellId returns an Excel cell id, e.g. C3, for given zero based column and row.
+func cellId(column, row int) string {
+	return fmt.Sprintf("%c%d", 'A'+column, row)
+}
+
+// FormatDuration returns string representation of given duration in format HH:MM.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	d = d.Round(time.Minute)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	return fmt.Sprintf("%s%02d:%02d", sign, h, m)
+}
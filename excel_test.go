@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type ExcelReportFormatterTestSuite struct {
+	suite.Suite
+}
+
+func TestExcelReportFormatterTestSuite(t *testing.T) {
+	suite.Run(t, new(ExcelReportFormatterTestSuite))
+}
+
+func (suite *ExcelReportFormatterTestSuite) TestWritePeriodReport() {
+
+	formatter := newExcelReportFormatter(loggerForTest())
+	formatter.WithHolidays([]timetracker.Holiday{
+		{Date: timetracker.Date{Year: 2022, Month: 1, Day: 1}, Description: "New Year"},
+	})
+
+	buf, err := formatter.WritePeriodReportToBuffer(periodReportForTest())
+	suite.Nil(err)
+	suite.True(buf.Len() > 0)
+	suite.Equal(".xlsx", formatter.FileExtension())
+}
+
+func (suite *ExcelReportFormatterTestSuite) TestWriteYearlyReport() {
+
+	formatter := newExcelReportFormatter(loggerForTest())
+	report := &yearlyReport{
+		Year: 2022,
+		Months: []monthlySummary{
+			{Month: 1, Workdays: 21, WorkingTime: 170 * time.Hour, ExpectedWorkingTime: 168 * time.Hour, Overtime: 2 * time.Hour, OvertimeBalance: 2 * time.Hour},
+			{Month: 2, Workdays: 20, WorkingTime: 157 * time.Hour, ExpectedWorkingTime: 160 * time.Hour, Overtime: -3 * time.Hour, OvertimeBalance: -1 * time.Hour},
+		},
+		TotalWorkingTime:    327 * time.Hour,
+		ExpectedWorkingTime: 328 * time.Hour,
+		Overtime:            -1 * time.Hour,
+	}
+
+	buf, err := formatter.WriteYearlyReportToBuffer(report)
+	suite.Nil(err)
+	suite.True(buf.Len() > 0)
+}
+
+func (suite *ExcelReportFormatterTestSuite) TestFormatDuration() {
+
+	suite.Equal("08:30", formatDuration(8*time.Hour+30*time.Minute))
+	suite.Equal("-01:15", formatDuration(-75*time.Minute))
+	suite.Equal("00:00", formatDuration(0))
+}
+
+func periodReportForTest() *periodReport {
+	start := time.Date(2021, 12, 27, 0, 0, 0, 0, time.UTC)
+	return &periodReport{
+		Start:    start,
+		End:      start.AddDate(0, 0, 7).Add(-1 * time.Second),
+		Location: newLocale(configForTest()),
+		Days: []timetracker.Day{
+			{
+				Date:        timetracker.Date{Year: 2021, Month: 12, Day: 31},
+				Type:        timetracker.WORKDAY,
+				WorkingTime: 8 * time.Hour,
+				BreakTime:   30 * time.Minute,
+				Events: []timetracker.TimeTrackingRecord{
+					{Type: timetracker.WORKDAY, Timestamp: time.Date(2021, 12, 31, 8, 0, 0, 0, time.UTC)},
+					{Type: timetracker.WORKDAY, Timestamp: time.Date(2021, 12, 31, 16, 30, 0, 0, time.UTC)},
+				},
+			},
+			{
+				Date: timetracker.Date{Year: 2022, Month: 1, Day: 1},
+				Type: timetracker.WORKDAY,
+			},
+			{
+				Date: timetracker.Date{Year: 2022, Month: 1, Day: 2},
+				Type: timetracker.VACATION,
+			},
+		},
+		TotalWorkingTime: 8 * time.Hour,
+	}
+}
@@ -2,11 +2,25 @@ module github.com/tommzn/hob-report-generator
 
 go 1.19
 
-require github.com/tommzn/hob-timetracker v1.4.7
+require (
+	github.com/aws/aws-lambda-go v1.36.0
+	github.com/aws/aws-sdk-go v1.44.168
+	github.com/go-pdf/fpdf v0.8.0
+	github.com/pkg/sftp v1.13.5
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/stretchr/testify v1.8.1
+	github.com/tommzn/go-config v1.1.0
+	github.com/tommzn/go-log v1.2.2
+	github.com/tommzn/go-secrets v1.1.2
+	github.com/tommzn/hob-core v1.0.5
+	github.com/tommzn/hob-timetracker v1.4.7
+	github.com/xuri/excelize/v2 v2.6.1
+	golang.org/x/crypto v0.0.0-20220817201139-bc19a97f63c8
+	golang.org/x/exp v0.0.0-20221114191408-850992195362
+	google.golang.org/protobuf v1.28.1
+)
 
 require (
-	github.com/aws/aws-lambda-go v1.36.0 // indirect
-	github.com/aws/aws-sdk-go v1.44.168 // indirect
 	github.com/calendarific/go-calendarific v0.0.0-20221115171631-30c5173a0a3f // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fsnotify/fsnotify v1.4.9 // indirect
@@ -14,6 +28,7 @@ require (
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/magiconair/properties v1.8.5 // indirect
 	github.com/mitchellh/mapstructure v1.4.1 // indirect
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
@@ -27,22 +42,13 @@ require (
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/spf13/viper v1.7.1 // indirect
-	github.com/stretchr/testify v1.8.1 // indirect
 	github.com/subosito/gotenv v1.2.0 // indirect
-	github.com/tommzn/go-config v1.1.0 // indirect
-	github.com/tommzn/go-log v1.2.2 // indirect
-	github.com/tommzn/go-secrets v1.1.2 // indirect
 	github.com/tommzn/go-utils v1.0.2 // indirect
-	github.com/tommzn/hob-core v1.0.5 // indirect
 	github.com/xuri/efp v0.0.0-20220603152613-6918739fd470 // indirect
-	github.com/xuri/excelize/v2 v2.6.1 // indirect
 	github.com/xuri/nfp v0.0.0-20220409054826-5e722a1d9e22 // indirect
-	golang.org/x/crypto v0.0.0-20220817201139-bc19a97f63c8 // indirect
-	golang.org/x/exp v0.0.0-20221114191408-850992195362 // indirect
 	golang.org/x/net v0.1.0 // indirect
 	golang.org/x/sys v0.1.0 // indirect
 	golang.org/x/text v0.4.0 // indirect
-	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/ini.v1 v1.62.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -1,198 +1,1061 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	config "github.com/tommzn/go-config"
 	log "github.com/tommzn/go-log"
 	core "github.com/tommzn/hob-core"
 	timetracker "github.com/tommzn/hob-timetracker"
+	"golang.org/x/exp/slices"
 )
 
-// HandleEvents will process given SQS events to generate time tracking reports.
-func (handler *ReportGenerator) HandleEvents(ctx context.Context, sqsEvent events.SQSEvent) error {
+// DateRangeLayout is used for start and end date of date range reports.
+const dateRangeLayout = "2006-01-02"
+
+// FileNamePlaceholder matches placeholders {week} and {end:<layout>} in report name patterns.
+var fileNamePlaceholder = regexp.MustCompile(`\{(week|end:[^}]*)\}`)
+
+// ErrNoReportDelivery is returned if a request doesn't define any delivery target.
+var errNoReportDelivery = errors.New("No report delivery defined!")
+
+// ErrReportUnchanged is returned if a report has already been delivered with identical content.
+var errReportUnchanged = errors.New("Report is unchanged!")
+
+// ReportFormatNames maps names used in report options to report formats.
+var reportFormatNames = map[string]core.ReportFormat{
+	"excel": core.ReportFormat_EXCEL,
+	"csv":   reportFormatCsv,
+	"pdf":   reportFormatPdf,
+	"html":  reportFormatHtml,
+	"json":  reportFormatJson,
+}
+
+// DeliveryTargetNames contains all delivery targets defined in config which can be used in report options.
+var deliveryTargetNames = []string{deliveryTargetWebhook, deliveryTargetSftp}
+
+// HandleEvents will process given SQS events to generate time tracking reports. Each message is processed independently
+// and ids of all failed messages are returned as batch item failures, so only these messages will be retried.
+// Up to configured number of batch workers messages are processed at the same time. If remaining time until
+// deadline of passed context is below configured safety margin, no further message is processed. These messages
+// are returned as batch item failures as well, so they will be delivered again. A message which causes a panic
+// is marked as failed without affecting other messages of a batch.
+func (handler *ReportGenerator) HandleEvents(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
 
 	defer handler.logger.Flush()
 
-	for _, message := range sqsEvent.Records {
+	failed := make([]bool, len(sqsEvent.Records))
+	runConcurrently(len(sqsEvent.Records), handler.batchWorkers, func(idx int) {
+
+		message := sqsEvent.Records[idx]
+		defer func() {
+			if r := recover(); r != nil {
+				handler.logger.Errorf("Unable to process message %s, reason: %v", message.MessageId, r)
+				failed[idx] = true
+			}
+		}()
 
+		if handler.deadlineReached(ctx) {
+			handler.logger.Infof("Deadline reached, defer message %s.", message.MessageId)
+			failed[idx] = true
+			return
+		}
 		handler.logger.Debugf("Receive: %+v", message)
 		handler.logger.Debugf("Process message %s for event source %s", message.MessageId, message.EventSource)
 
-		request := &core.GenerateReportRequest{}
-		content := unwrapAwsEventBridgeTrigger(message.Body)
-		if err := core.DeserializeEvent(content, request); err != nil {
-			handler.logger.Error("Unable to deserialize event, reason: ", err)
-			return err
+		messageCtx, cancel := handler.messageContext(ctx)
+		defer cancel()
+		if err := handler.processMessage(messageCtx, message); err != nil {
+			handler.logger.Errorf("Unable to process message %s, reason: %s", message.MessageId, err)
+			failed[idx] = true
 		}
-		handler.logger.Debugf("Request: %+v", request)
+	})
 
-		formatter, err := newReportFormatter(request, handler.logger)
-		if err != nil {
-			handler.logger.Error("Unable to create formatter, reason: ", err)
-			return err
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+	for idx, message := range sqsEvent.Records {
+		if failed[idx] {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
 		}
-		handler.formatter = formatter
+	}
+	return response, nil
+}
 
-		publisher, err := handler.newReportPublisher(request)
-		if err != nil {
-			handler.logger.Error("Unable to create publisher, reason: ", err)
-			return err
-		}
-		handler.publisher = publisher
+// DeadlineReached returns true if remaining time until deadline of given context is below configured safety margin.
+// A context without deadline never reaches it.
+func (handler *ReportGenerator) deadlineReached(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < handler.safetyMargin
+}
 
-		if len(request.DeviceIds) == 0 {
-			request.DeviceIds = handler.deviceIds
-		}
+// MessageContext returns a context for a single message which is done a safety margin before deadline of given
+// context. A message which can't be completed in time fails, so there's time left to return all failed messages.
+func (handler *ReportGenerator) messageContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(ctx, deadline.Add(-handler.safetyMargin))
+	}
+	return context.WithCancel(ctx)
+}
 
-		if err := handler.GenerateReport(request); err != nil {
-			handler.logger.Error("Unable to generate report, reason: ", err)
-			return err
-		}
+// ProcessMessage extracts a report request from given SQS message and generates a report.
+// A request which has already been completed is skipped, unless it's forced.
+func (handler *ReportGenerator) processMessage(ctx context.Context, message events.SQSMessage) error {
+
+	request := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{}}
+	content := unwrapAwsEventBridgeTrigger(message.Body)
+	request.reportOptions = unwrapReportOptions(message.Body)
+	if err := core.DeserializeEvent(content, request.GenerateReportRequest); err != nil {
+		handler.logger.Error("Unable to deserialize event, reason: ", err)
+		return err
+	}
+	handler.logger.Debugf("Request: %+v", request)
+
+	key, err := handler.idempotencyKey(request)
+	if err != nil {
+		return err
 	}
+	if !request.Force && handler.isCompleted(key) {
+		handler.logger.Infof("Request %s has already been completed, skip it.", key)
+		return nil
+	}
+	if err := handler.processRequest(ctx, request); err != nil {
+		return err
+	}
+	handler.markCompleted(key)
 	return nil
 }
 
+// ProcessRequest creates a pipeline for given request and generates a report. Passed publishers are used in addition
+// to all delivery targets of a request, a request doesn't need a delivery target in this case.
+// Fetching time tracking records stops if passed context is done.
+func (handler *ReportGenerator) processRequest(ctx context.Context, request *reportRequest, additionalPublisher ...timetracker.ReportPublisher) error {
+
+	pipeline, err := handler.newPipeline(ctx, request, additionalPublisher...)
+	if err != nil {
+		return err
+	}
+	return pipeline.run(request)
+}
+
+// NewPipeline creates formatters, publishers and a calculator for given request. Passed publishers are used in addition
+// to all delivery targets of a request.
+func (handler *ReportGenerator) newPipeline(ctx context.Context, request *reportRequest, additionalPublisher ...timetracker.ReportPublisher) (*reportPipeline, error) {
+
+	formatter, err := newReportFormatters(request, handler.logger)
+	if err != nil {
+		handler.logger.Error("Unable to create formatter, reason: ", err)
+		return nil, err
+	}
+
+	publisher, err := handler.newReportPublisher(request)
+	if err != nil && (err != errNoReportDelivery || len(additionalPublisher) == 0) {
+		handler.logger.Error("Unable to create publisher, reason: ", err)
+		return nil, err
+	}
+
+	publisher = append(publisher, additionalPublisher...)
+	for _, reportPublisher := range publisher {
+		if contextPublisher, ok := reportPublisher.(contextAwarePublisher); ok {
+			contextPublisher.WithContext(ctx)
+		}
+	}
+
+	return &reportPipeline{
+		ReportGenerator: handler,
+		ctx:             ctx,
+		calculator:      newReportCalulator(handler.locale),
+		formatter:       formatter,
+		publisher:       publisher,
+	}, nil
+}
+
+// Run generates a report for given request. Devices of a report generator are used if a request doesn't define any device.
+func (pipeline *reportPipeline) run(request *reportRequest) error {
+
+	if len(request.DeviceIds) == 0 {
+		request.DeviceIds = pipeline.deviceIds
+	}
+
+	err := pipeline.GenerateReport(request)
+	if err != nil && err != errReportUnchanged {
+		pipeline.logger.Error("Unable to generate report, reason: ", err)
+	}
+	return err
+}
+
 // GenerateReport will generate a report based on passed type.
-func (handler *ReportGenerator) GenerateReport(request *core.GenerateReportRequest) error {
+func (pipeline *reportPipeline) GenerateReport(request *reportRequest) error {
 
 	switch request.Type {
 
 	case core.ReportType_MONTHLY_REPORT:
-		return handler.GenerateMonthlyReport(request)
+		return pipeline.GenerateMonthlyReport(request)
+
+	case reportTypeWeekly:
+		return pipeline.GenerateWeeklyReport(request)
+
+	case reportTypeYearly:
+		return pipeline.GenerateYearlyReport(request)
+
+	case reportTypeDateRange:
+		return pipeline.GenerateDateRangeReport(request)
 
 	default:
 		err := fmt.Errorf("Unsupported report type: %s", request.Type)
-		handler.logger.Error(err)
+		pipeline.logger.Error(err)
 		return err
 	}
 }
 
 // GenerateMonthlyReport will fetch time tracking for last month, calculates a report, format it and distribute this report to a defined target.
-func (handler *ReportGenerator) GenerateMonthlyReport(request *core.GenerateReportRequest) error {
+func (pipeline *reportPipeline) GenerateMonthlyReport(request *reportRequest) error {
 
-	timeRangeStart, timeRangeEnd := reportTimeRange(request)
-	handler.logger.Debugf("Generate report for %s - %s", timeRangeStart.Format("2006-01-02T15:04:05"), timeRangeEnd.Format("2006-01-02T15:04:05"))
+	timeRangeStart, timeRangeEnd := reportTimeRange(request.GenerateReportRequest, pipeline.timezone())
+	pipeline.logger.Debugf("Generate report for %s - %s", timeRangeStart.Format("2006-01-02T15:04:05"), timeRangeEnd.Format("2006-01-02T15:04:05"))
 
 	year := timeRangeStart.Year()
 	month := int(timeRangeStart.Month())
-	var timeTrackingRecords []timetracker.TimeTrackingRecord
-	for _, deviceId := range request.DeviceIds {
-		deviceRecords, err := handler.timeTracker.ListRecords(deviceId, timeRangeStart, timeRangeEnd)
-		if err != nil {
-			return err
-		}
-		timeTrackingRecords = append(timeTrackingRecords, deviceRecords...)
+	timeTrackingRecords, err := pipeline.listRecords(request.DeviceIds, timeRangeStart, timeRangeEnd)
+	if err != nil {
+		return err
 	}
-	handler.calculator.WithTimeTrackingRecords(timeTrackingRecords)
+	pipeline.calculator.WithTimeTrackingRecords(timeTrackingRecords)
 
 	recordsJson, _ := json.Marshal(timeTrackingRecords)
-	handler.logger.Debugf("TimeTrackingRecords: %s", string(recordsJson))
+	pipeline.logger.Debugf("TimeTrackingRecords: %s", string(recordsJson))
 
-	if handler.calendar != nil {
-		if holidays, err := handler.calendar.GetHolidays(year, month); err == nil {
-			handler.formatter.WithHolidays(holidays)
+	holidays := []timetracker.Holiday{}
+	if pipeline.calendar != nil {
+		if monthlyHolidays, err := pipeline.calendar.GetHolidays(year, month); err == nil {
+			holidays = monthlyHolidays
 		}
 	}
 
-	monthlyReport, err := handler.calculator.MonthlyReport(year, month, timetracker.WORKDAY)
+	monthlyReport, err := pipeline.calculator.MonthlyReport(year, month, timetracker.WORKDAY)
 	if err != nil {
 		return err
 	}
 	monthlyReportJson, _ := json.Marshal(monthlyReport)
-	handler.logger.Debugf("MonthlyReport: %s", string(monthlyReportJson))
+	pipeline.logger.Debugf("MonthlyReport: %s", string(monthlyReportJson))
+
+	return pipeline.formatAndPublish(request, &generatedReport{
+		Start:     timeRangeStart,
+		End:       timeRangeEnd,
+		DeviceIds: request.DeviceIds,
+		Report:    monthlyReport,
+		Holidays:  holidays,
+	})
+}
+
+// GenerateWeeklyReport will fetch time tracking records for an ISO week, calculates a report for all days of this week,
+// format it and distribute this report to all defined targets.
+func (pipeline *reportPipeline) GenerateWeeklyReport(request *reportRequest) error {
+
+	timeRangeStart, timeRangeEnd, err := weeklyReportTimeRange(request, pipeline.timezone())
+	if err != nil {
+		return err
+	}
+	pipeline.logger.Debugf("Generate weekly report for %s - %s", timeRangeStart.Format("2006-01-02T15:04:05"), timeRangeEnd.Format("2006-01-02T15:04:05"))
+	return pipeline.generatePeriodReport(request, timeRangeStart, timeRangeEnd)
+}
+
+// GenerateDateRangeReport will fetch time tracking records for a time range defined by start and end date of passed request,
+// calculates a report for all days of this range, format it and distribute this report to all defined targets.
+func (pipeline *reportPipeline) GenerateDateRangeReport(request *reportRequest) error {
+
+	timeRangeStart, timeRangeEnd, err := dateRangeReportTimeRange(request, pipeline.maxReportDays(), pipeline.timezone())
+	if err != nil {
+		return err
+	}
+	pipeline.logger.Debugf("Generate report for %s - %s", timeRangeStart.Format("2006-01-02T15:04:05"), timeRangeEnd.Format("2006-01-02T15:04:05"))
+	return pipeline.generatePeriodReport(request, timeRangeStart, timeRangeEnd)
+}
+
+// GeneratePeriodReport fetches time tracking records for given time range, calculates a report with all days
+// of this range and distributes it to all defined targets.
+func (pipeline *reportPipeline) generatePeriodReport(request *reportRequest, timeRangeStart, timeRangeEnd time.Time) error {
+
+	timeTrackingRecords, err := pipeline.listRecords(request.DeviceIds, timeRangeStart, timeRangeEnd)
+	if err != nil {
+		return err
+	}
+	pipeline.calculator.WithTimeTrackingRecords(timeTrackingRecords)
+
+	report, holidays, err := pipeline.calculatePeriodReport(timeRangeStart, timeRangeEnd)
+	if err != nil {
+		return err
+	}
+
+	return pipeline.formatAndPublish(request, &generatedReport{
+		Start:     timeRangeStart,
+		End:       timeRangeEnd,
+		DeviceIds: request.DeviceIds,
+		Report:    report,
+		Holidays:  holidays,
+	})
+}
+
+// MaxReportDays returns the max number of days a date range report can include.
+// Can be defined by config key hob.report.max_days, default is 366 days.
+func (handler *ReportGenerator) maxReportDays() int {
+	maxDays := 366
+	if handler.conf != nil {
+		maxDays = *handler.conf.GetAsInt("hob.report.max_days", &maxDays)
+	}
+	return maxDays
+}
+
+// GenerateYearlyReport will fetch time tracking records of an entire year, calculates a summary for each month
+// together with a running overtime balance and distributes this report to all defined targets.
+func (pipeline *reportPipeline) GenerateYearlyReport(request *reportRequest) error {
+
+	timeRangeStart, timeRangeEnd := yearlyReportTimeRange(request.GenerateReportRequest, pipeline.timezone())
+	pipeline.logger.Debugf("Generate yearly report for %s - %s", timeRangeStart.Format("2006-01-02T15:04:05"), timeRangeEnd.Format("2006-01-02T15:04:05"))
+
+	timeTrackingRecords, err := pipeline.listRecords(request.DeviceIds, timeRangeStart, timeRangeEnd)
+	if err != nil {
+		return err
+	}
+	pipeline.calculator.WithTimeTrackingRecords(timeTrackingRecords)
 
-	reportBuffer, err := handler.formatter.WriteMonthlyReportToBuffer(monthlyReport)
+	report, holidays, err := pipeline.calculateYearlyReport(timeRangeStart.Year())
 	if err != nil {
 		return err
 	}
+	yearlyReportJson, _ := json.Marshal(report)
+	pipeline.logger.Debugf("YearlyReport: %s", string(yearlyReportJson))
+
+	return pipeline.formatAndPublish(request, &generatedReport{
+		Start:     timeRangeStart,
+		End:       timeRangeEnd,
+		DeviceIds: request.DeviceIds,
+		Report:    report,
+		Holidays:  holidays,
+	})
+}
+
+// CalculateYearlyReport creates a monthly report for each month of given year and summarizes
+// working time, expected working time and overtime of all these months. Holidays of the entire year are returned as well.
+func (pipeline *reportPipeline) calculateYearlyReport(year int) (*yearlyReport, []timetracker.Holiday, error) {
+
+	report := &yearlyReport{Year: year, Months: []monthlySummary{}}
+	holidaysOfYear := []timetracker.Holiday{}
+	for month := 1; month <= 12; month++ {
+
+		monthlyReport, err := pipeline.calculator.MonthlyReport(year, month, timetracker.WORKDAY)
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Location = monthlyReport.Location
+
+		holidays := []timetracker.Holiday{}
+		if pipeline.calendar != nil {
+			if monthlyHolidays, err := pipeline.calendar.GetHolidays(year, month); err == nil {
+				holidays = monthlyHolidays
+			}
+		}
+		holidaysOfYear = append(holidaysOfYear, holidays...)
+
+		summary := newMonthlySummary(monthlyReport, holidays)
+		report.TotalWorkingTime += summary.WorkingTime
+		report.ExpectedWorkingTime += summary.ExpectedWorkingTime
+		report.Overtime += summary.Overtime
+		summary.OvertimeBalance = report.Overtime
+		report.Months = append(report.Months, summary)
+	}
+	return report, holidaysOfYear, nil
+}
+
+// ListRecords fetches time tracking records of all passed devices for given time range.
+func (pipeline *reportPipeline) listRecords(deviceIds []string, start, end time.Time) ([]timetracker.TimeTrackingRecord, error) {
+
+	type deviceResult struct {
+		idx     int
+		records []timetracker.TimeTrackingRecord
+		err     error
+	}
+
+	// Results channel is buffered, so pending fetches don't block if records are no longer collected.
+	results := make(chan deviceResult, len(deviceIds))
+	go runConcurrently(len(deviceIds), pipeline.fetchWorkers, func(idx int) {
+		if err := pipeline.ctx.Err(); err != nil {
+			results <- deviceResult{idx: idx, err: err}
+			return
+		}
+		records, err := pipeline.timeTracker.ListRecords(deviceIds[idx], start, end)
+		results <- deviceResult{idx: idx, records: records, err: err}
+	})
+
+	errs := deviceErrors{}
+	fetched := make([]bool, len(deviceIds))
+	deviceRecords := make([][]timetracker.TimeTrackingRecord, len(deviceIds))
+	for received := 0; received < len(deviceIds); received++ {
+		select {
+		case result := <-results:
+			fetched[result.idx] = true
+			if result.err != nil {
+				errs[deviceIds[result.idx]] = result.err
+			}
+			deviceRecords[result.idx] = result.records
+		case <-pipeline.ctx.Done():
+			for idx, deviceId := range deviceIds {
+				if !fetched[idx] {
+					errs[deviceId] = pipeline.ctx.Err()
+				}
+			}
+			return nil, errs
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var timeTrackingRecords []timetracker.TimeTrackingRecord
+	for _, records := range deviceRecords {
+		timeTrackingRecords = append(timeTrackingRecords, records...)
+	}
+	return timeTrackingRecords, nil
+}
+
+// CalculatePeriodReport calculates monthly reports for all months in given time range and collects all days
+// of this range to a single report. Holidays of all these months are returned as well.
+// Days are compared with dates of start and end in their timezone.
+func (pipeline *reportPipeline) calculatePeriodReport(start, end time.Time) (*periodReport, []timetracker.Holiday, error) {
+
+	report := &periodReport{Start: start, End: end, Days: []timetracker.Day{}}
+	holidays := []timetracker.Holiday{}
+	firstDay, lastDay := asUtcDate(start), asUtcDate(end)
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(lastDay); month = month.AddDate(0, 1, 0) {
+
+		monthlyReport, err := pipeline.calculator.MonthlyReport(month.Year(), int(month.Month()), timetracker.WORKDAY)
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Location = monthlyReport.Location
+		for _, day := range daysInMonth(monthlyReport) {
+			if dayTime := day.Date.AsTime(); !dayTime.Before(firstDay) && !dayTime.After(lastDay) {
+				report.Days = append(report.Days, day)
+				report.TotalWorkingTime += day.WorkingTime
+			}
+		}
+
+		if pipeline.calendar != nil {
+			if monthlyHolidays, err := pipeline.calendar.GetHolidays(month.Year(), int(month.Month())); err == nil {
+				holidays = append(holidays, monthlyHolidays...)
+			}
+		}
+	}
+	return report, holidays, nil
+}
+
+// FormatAndPublish writes given report with all formatters of current request and sends each output to all publishers.
+// If archive option is set, all files are packed into a single ZIP archive which is send instead of single files.
+// Publishers which depend on report details, e.g. to render an email body, will get passed report before
+// and publishers which have to know about all published files will be notified afterwards.
+// A failed format doesn't stop other formats, all failures are returned as formatErrors.
+// If checksums of delivered reports are available, an unchanged report isn't delivered again.
+func (pipeline *reportPipeline) formatAndPublish(request *reportRequest, report *generatedReport) error {
+
+	baseFileName := reportFileName(request.NamePattern, report.Start, report.End)
+	checksum := ""
+	if pipeline.checksums != nil {
+		checksum = reportChecksum(request, report)
+		if deliveredChecksum, err := pipeline.checksums.checksum(baseFileName); err != nil {
+			pipeline.logger.Error("Unable to get checksum of delivered report, reason: ", err)
+		} else if deliveredChecksum == checksum {
+			pipeline.logger.Debugf("Report %s is unchanged, skip delivery.", baseFileName)
+			return errReportUnchanged
+		}
+	}
+
+	for _, publisher := range pipeline.publisher {
+		if reportPublisher, ok := publisher.(reportAwarePublisher); ok {
+			if err := reportPublisher.WithReport(report); err != nil {
+				return err
+			}
+		}
+	}
+
+	errs := formatErrors{}
+	reportFiles := []reportFile{}
+	for _, formatter := range pipeline.formatter {
+
+		formatter.WithHolidays(report.Holidays)
+		reportBuffer, err := formatReport(formatter, report.Report)
+		if err != nil {
+			pipeline.logger.Errorf("Unable to format report with %T, reason: %s", formatter, err)
+			errs[formatter.FileExtension()] = err
+			continue
+		}
+		reportFiles = append(reportFiles, reportFile{name: baseFileName + formatter.FileExtension(), content: reportBuffer.Bytes()})
+	}
+
+	if request.Archive && len(reportFiles) > 0 {
+		archive, err := newReportArchive(reportFiles)
+		if err != nil {
+			errs[archiveFileExtension] = err
+			return errs
+		}
+		reportFiles = []reportFile{{name: baseFileName + archiveFileExtension, content: archive.Bytes()}}
+	}
+
+	published := 0
+	for _, file := range reportFiles {
+		if err := pipeline.publish(file.content, file.name); err != nil {
+			pipeline.logger.Errorf("Unable to publish %s, reason: %s", file.name, err)
+			errs[filepath.Ext(file.name)] = err
+			continue
+		}
+		published++
+	}
 
-	reportFileName := timeRangeStart.Format(request.NamePattern) + handler.formatter.FileExtension()
-	for _, publisher := range handler.publisher {
-		handler.logger.Debugf("Publish %s using %T", reportFileName, publisher)
-		if err := publisher.Send(reportBuffer.Bytes(), reportFileName); err != nil {
+	if published > 0 {
+		for _, publisher := range pipeline.publisher {
+			if reportPublisher, ok := publisher.(completingPublisher); ok {
+				if err := reportPublisher.Complete(); err != nil {
+					pipeline.logger.Errorf("Unable to complete delivery with %T, reason: %s", publisher, err)
+					return err
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	if pipeline.checksums != nil {
+		if err := pipeline.checksums.saveChecksum(baseFileName, checksum); err != nil {
+			pipeline.logger.Error("Unable to save checksum of delivered report, reason: ", err)
+		}
+	}
+	return nil
+}
+
+// Publish sends given report content to all publishers of current request.
+func (pipeline *reportPipeline) publish(content []byte, reportFileName string) error {
+	for _, publisher := range pipeline.publisher {
+		pipeline.logger.Debugf("Publish %s using %T", reportFileName, publisher)
+		if err := publisher.Send(content, reportFileName); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// ReportTimeRange generates first amd last day for report time range.
-func reportTimeRange(request *core.GenerateReportRequest) (time.Time, time.Time) {
+// FormatReport writes passed report, a monthly, period or yearly report, with given formatter.
+// Returns with an error if given formatter doesn't support this kind of report.
+func formatReport(formatter timetracker.ReportFormatter, report interface{}) (*bytes.Buffer, error) {
+
+	switch report := report.(type) {
+
+	case *timetracker.MonthlyReport:
+		return formatter.WriteMonthlyReportToBuffer(report)
+
+	case *periodReport:
+		if periodFormatter, ok := formatter.(periodReportFormatter); ok {
+			return periodFormatter.WritePeriodReportToBuffer(report)
+		}
+
+	case *yearlyReport:
+		if yearlyFormatter, ok := formatter.(yearlyReportFormatter); ok {
+			return yearlyFormatter.WriteYearlyReportToBuffer(report)
+		}
+	}
+	return nil, fmt.Errorf("Report %T is not supported by formatter %T", report, formatter)
+}
+
+// ReportTimeRange generates first amd last day for report time range in given timezone.
+func reportTimeRange(request *core.GenerateReportRequest, timezone *time.Location) (time.Time, time.Time) {
 
 	if request.Year >= 2000 &&
 		request.Month >= 1 && request.Month <= 12 {
-		firstOfThisMonth := time.Date(int(request.Year), time.Month(request.Month), 1, 0, 0, 0, 0, time.UTC)
+		firstOfThisMonth := time.Date(int(request.Year), time.Month(request.Month), 1, 0, 0, 0, 0, timezone)
 		return firstOfThisMonth, firstOfThisMonth.AddDate(0, 1, 0).Add(-1 * time.Second)
 	} else {
-		now := time.Now()
-		firstOfThisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		now := time.Now().In(timezone)
+		firstOfThisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, timezone)
 		return firstOfThisMonth.AddDate(0, -1, 0), firstOfThisMonth.Add(-1 * time.Second)
 	}
 }
 
-// NewReportFormatter returns an Excel report formatter with default settings.
+// WeeklyReportTimeRange generates first and last second of an ISO week in given timezone. Previous ISO week is used
+// if there's no week in passed request. Returns with an error if a week doesn't exist in year of a request.
+func weeklyReportTimeRange(request *reportRequest, timezone *time.Location) (time.Time, time.Time, error) {
+
+	if request.Week == 0 {
+		firstDayOfThisWeek := inTimezone(firstDayOfIsoWeek(time.Now().In(timezone).ISOWeek()), timezone)
+		return firstDayOfThisWeek.AddDate(0, 0, -7), firstDayOfThisWeek.Add(-1 * time.Second), nil
+	}
+	if request.Year >= 2000 && request.Week >= 1 && request.Week <= 53 {
+		firstDayOfWeek := inTimezone(firstDayOfIsoWeek(int(request.Year), int(request.Week)), timezone)
+		if _, week := firstDayOfWeek.ISOWeek(); week == int(request.Week) {
+			return firstDayOfWeek, firstDayOfWeek.AddDate(0, 0, 7).Add(-1 * time.Second), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("Invalid week %d of year %d", request.Week, request.Year)
+}
+
+// DateRangeReportTimeRange parses start and end date, format YYYY-MM-DD, of passed request and returns a time range
+// from start of first day until end of last day in given timezone. Start has to be before end and the range must not exceed
+// given max number of days.
+func dateRangeReportTimeRange(request *reportRequest, maxDays int, timezone *time.Location) (time.Time, time.Time, error) {
+
+	startDate, err := time.ParseInLocation(dateRangeLayout, request.StartDate, timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid start date: %s", request.StartDate)
+	}
+	endDate, err := time.ParseInLocation(dateRangeLayout, request.EndDate, timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid end date: %s", request.EndDate)
+	}
+	if !startDate.Before(endDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("Start date %s has to be before end date %s", request.StartDate, request.EndDate)
+	}
+	timeRangeEnd := endDate.AddDate(0, 0, 1).Add(-1 * time.Second)
+	if days := int(asUtcDate(endDate).Sub(asUtcDate(startDate)).Hours()/24) + 1; days > maxDays {
+		return time.Time{}, time.Time{}, fmt.Errorf("Date range of %d days exceeds max range of %d days", days, maxDays)
+	}
+	return startDate, timeRangeEnd, nil
+}
+
+// ReportFileName generates a file name by given pattern. Time layouts in a pattern are formatted with start of a report period.
+// Use {end:<layout>}, e.g. {end:20060102}, to add end of a report period and {week} to add the ISO week of a report start.
+func reportFileName(pattern string, start, end time.Time) string {
+
+	fileName := ""
+	position := 0
+	for _, match := range fileNamePlaceholder.FindAllStringSubmatchIndex(pattern, -1) {
+		fileName += start.Format(pattern[position:match[0]])
+		placeholder := pattern[match[2]:match[3]]
+		if placeholder == "week" {
+			_, week := start.ISOWeek()
+			fileName += fmt.Sprintf("%02d", week)
+		} else {
+			fileName += end.Format(strings.TrimPrefix(placeholder, "end:"))
+		}
+		position = match[1]
+	}
+	return fileName + start.Format(pattern[position:])
+}
+
+// YearlyReportTimeRange generates first and last second of a year in given timezone. Previous year is used
+// if there's no year in passed request.
+func yearlyReportTimeRange(request *core.GenerateReportRequest, timezone *time.Location) (time.Time, time.Time) {
+
+	year := time.Now().In(timezone).Year() - 1
+	if request.Year >= 2000 {
+		year = int(request.Year)
+	}
+	firstOfYear := time.Date(year, 1, 1, 0, 0, 0, 0, timezone)
+	return firstOfYear, firstOfYear.AddDate(1, 0, 0).Add(-1 * time.Second)
+}
+
+// NewMonthlySummary calculates working time, expected working time and overtime for given report.
+// Expected working time is based on default working time of report locale for each day from Monday to Friday,
+// excluding holidays, vacation and illness.
+func newMonthlySummary(report *timetracker.MonthlyReport, holidays []timetracker.Holiday) monthlySummary {
+
+	summary := monthlySummary{Month: report.Month, WorkingTime: report.TotalWorkingTime}
+	summary.Workdays = countWorkdays(daysInMonth(report), holidays)
+	summary.ExpectedWorkingTime = time.Duration(summary.Workdays) * report.Location.DefaultWorkTime
+	summary.Overtime = summary.WorkingTime - summary.ExpectedWorkingTime
+	return summary
+}
+
+// CountWorkdays returns number of passed days which are expected to be worked. Weekends, public holidays,
+// vacation and illness are not counted as workdays.
+func countWorkdays(days []timetracker.Day, holidays []timetracker.Holiday) int {
+
+	isHoliday := make(map[timetracker.Date]bool)
+	for _, holiday := range holidays {
+		isHoliday[holiday.Date] = true
+	}
+
+	workdays := 0
+	for _, day := range days {
+		weekday := day.Date.AsTime().Weekday()
+		if weekday != time.Saturday && weekday != time.Sunday &&
+			!isHoliday[day.Date] &&
+			day.Type != timetracker.VACATION && day.Type != timetracker.ILLNESS {
+			workdays++
+		}
+	}
+	return workdays
+}
+
+// NewReportSummary returns period, devices, locale and totals of a generated report, independent of its type.
+func newReportSummary(report *generatedReport) reportSummary {
+
+	summary := reportSummary{Start: report.Start, End: report.End, Devices: report.DeviceIds}
+	switch content := report.Report.(type) {
+
+	case *timetracker.MonthlyReport:
+		monthlySummary := newMonthlySummary(content, report.Holidays)
+		summary.Period = fmt.Sprintf("%04d-%02d", content.Year, content.Month)
+		summary.Locale = content.Location
+		summary.Workdays = monthlySummary.Workdays
+		summary.TotalWorkingTime = monthlySummary.WorkingTime
+		summary.ExpectedWorkingTime = monthlySummary.ExpectedWorkingTime
+
+	case *periodReport:
+		summary.Period = content.Start.Format(dateRangeLayout) + " - " + content.End.Format(dateRangeLayout)
+		summary.Locale = content.Location
+		summary.Workdays = countWorkdays(content.Days, report.Holidays)
+		summary.TotalWorkingTime = content.TotalWorkingTime
+		summary.ExpectedWorkingTime = time.Duration(summary.Workdays) * content.Location.DefaultWorkTime
+
+	case *yearlyReport:
+		summary.Period = strconv.Itoa(content.Year)
+		summary.Locale = content.Location
+		for _, month := range content.Months {
+			summary.Workdays += month.Workdays
+		}
+		summary.TotalWorkingTime = content.TotalWorkingTime
+		summary.ExpectedWorkingTime = content.ExpectedWorkingTime
+	}
+	summary.Overtime = summary.TotalWorkingTime - summary.ExpectedWorkingTime
+	return summary
+}
+
+// FirstDayOfIsoWeek returns Monday of given ISO week at 00:00:00 UTC.
+func firstDayOfIsoWeek(year, week int) time.Time {
+	// 4th of January is always part of first ISO week.
+	fourthOfJanuary := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+	daysSinceMonday := (int(fourthOfJanuary.Weekday()) + 6) % 7
+	return fourthOfJanuary.AddDate(0, 0, (week-1)*7-daysSinceMonday)
+}
+
+// DaysInMonth returns all calendar days of given monthly report. Days without time tracking records are added as empty workdays.
+func daysInMonth(report *timetracker.MonthlyReport) []timetracker.Day {
+
+	days := make(map[timetracker.Date]timetracker.Day)
+	for _, day := range report.Days {
+		days[day.Date] = day
+	}
+
+	allDays := []timetracker.Day{}
+	firstOfMonth := time.Date(report.Year, time.Month(report.Month), 1, 0, 0, 0, 0, time.UTC)
+	for calendarDay := firstOfMonth; calendarDay.Month() == firstOfMonth.Month(); calendarDay = calendarDay.AddDate(0, 0, 1) {
+		date := timetracker.Date{Year: calendarDay.Year(), Month: int(calendarDay.Month()), Day: calendarDay.Day()}
+		if day, ok := days[date]; ok {
+			allDays = append(allDays, day)
+		} else {
+			allDays = append(allDays, timetracker.Day{Date: date, Type: timetracker.WORKDAY, Events: []timetracker.TimeTrackingRecord{}})
+		}
+	}
+	return allDays
+}
+
+// LoadTimezone returns time location of given locale, or nil if there's no timezone defined.
+func loadTimezone(locale timetracker.Locale) (*time.Location, error) {
+	if locale.Timezone == nil {
+		return nil, nil
+	}
+	return time.LoadLocation(*locale.Timezone)
+}
+
+// ReportFormats returns format of a request together with all additional formats from report options.
+// Format of a request is skipped if it's undefined and there're additional formats.
+// Each format is returned only once. Returns with an error if a format name is unknown.
+func (request *reportRequest) reportFormats() ([]core.ReportFormat, error) {
+
+	formats := []core.ReportFormat{}
+	if request.Format != core.ReportFormat_NO_FORMAT || len(request.Formats) == 0 {
+		formats = append(formats, request.Format)
+	}
+	for _, name := range request.Formats {
+		format, ok := reportFormatNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported report format: %s", name)
+		}
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return formats, nil
+}
+
+// DeliveryTargets returns all delivery targets defined in config which are selected by report options, in sorted order.
+// Each target is returned only once. Returns with an error if a target name is unknown.
+func (request *reportRequest) deliveryTargets() ([]string, error) {
+
+	targets := []string{}
+	for _, name := range request.Targets {
+		target := strings.ToLower(name)
+		if !slices.Contains(deliveryTargetNames, target) {
+			return nil, fmt.Errorf("Unsupported delivery target: %s", name)
+		}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// NewReportFormatters returns a formatter for each format of given request. These are the format of the request itself
+// and all formats defined by report options. Each format is used only once.
+func newReportFormatters(request *reportRequest, logger log.Logger) ([]timetracker.ReportFormatter, error) {
+
+	formatters := []timetracker.ReportFormatter{}
+	formats, err := request.reportFormats()
+	if err != nil {
+		return formatters, err
+	}
+	for _, format := range formats {
+		formatter, err := newReportFormatter(&core.GenerateReportRequest{Format: format}, logger)
+		if err != nil {
+			return formatters, err
+		}
+		formatters = append(formatters, formatter)
+	}
+	return formatters, nil
+}
+
+// NewReportFormatter returns a formatter for format of passed request.
 func newReportFormatter(request *core.GenerateReportRequest, logger log.Logger) (timetracker.ReportFormatter, error) {
 
 	switch request.Format {
 	case core.ReportFormat_EXCEL:
-		return timetracker.NewExcelReportFormatter(logger), nil
+		return newExcelReportFormatter(logger), nil
+	case reportFormatCsv:
+		return newCsvReportFormatter(logger), nil
+	case reportFormatPdf:
+		return newPdfReportFormatter(logger), nil
+	case reportFormatHtml:
+		return newHtmlReportFormatter(logger), nil
+	case reportFormatJson:
+		return newJsonReportFormatter(logger), nil
 	default:
 		return nil, fmt.Errorf("Unsupported report format: %s", request.Format)
 	}
 }
 
 // NewReportPublisher returns a publisher to ditribute a report to a target defined in given report generate request.
-func (handler *ReportGenerator) newReportPublisher(request *core.GenerateReportRequest) ([]timetracker.ReportPublisher, error) {
+// Reports can be embedded as HTML into an email body by mail options of a request. Email subject and message
+// are rendered from templates defined in config. Targets defined in config, e.g. a webhook or a SFTP server, are used
+// only if they're selected by report options.
+func (handler *ReportGenerator) newReportPublisher(request *reportRequest) ([]timetracker.ReportPublisher, error) {
 
 	publisher := []timetracker.ReportPublisher{}
+	locations := []string{}
+
+	targets, err := request.deliveryTargets()
+	if err != nil {
+		return publisher, err
+	}
+
+	// Requests without delivery targets may still select targets defined in config or chat channels.
+	delivery := request.Delivery
+	if delivery == nil {
+		delivery = &core.ReportDelivery{}
+	}
 
-	if request.Delivery.Mail != nil && len(request.Delivery.Mail.ToAddresses) > 0 {
+	if delivery.Mail != nil && len(delivery.Mail.ToAddresses) > 0 {
 
-		startTime, _ := reportTimeRange(request)
-		subject := startTime.Format("Time Tracking Report 200601")
-		message := "<p>PFA your monthly time tracking report!</p></br>"
 		if source := handler.conf.Get("hob.email.source", nil); source != nil {
-			publisher = append(publisher, timetracker.NewEMailPublisher(*source, request.Delivery.Mail.ToAddresses[0], subject, message))
+			recipients, err := handler.mailRecipients(delivery.Mail.ToAddresses)
+			if err != nil {
+				return publisher, err
+			}
+			templates, err := mailTemplatesFromConfig(handler.conf)
+			if err != nil {
+				return publisher, err
+			}
+			transport, err := mailTransportFromConfig(handler.conf, handler.secretsManager)
+			if err != nil {
+				return publisher, err
+			}
+			publisher = append(publisher, newMailPublisher(*source, recipients, templates, transport, request.Mail, handler.logger))
+		} else {
+			handler.logger.Debug("No email source defined!")
 		}
-		handler.logger.Debug("No email source defined!")
 	}
 
-	if request.Delivery.S3 != nil {
+	if delivery.S3 != nil {
 
-		region := handler.awsConf.region
-		if request.Delivery.S3.Region != "" {
-			region = &request.Delivery.S3.Region
-		}
+		region, bucket, basePath := handler.s3Target(delivery.S3)
+		publisher = append(publisher, timetracker.NewS3Publisher(region, bucket, basePath, handler.logger))
+		locations = append(locations, s3Location(bucket, basePath))
+	}
+
+	if delivery.File != nil {
+		publisher = append(publisher, timetracker.NewFilePublisher(&delivery.File.Path, handler.logger))
+		locations = append(locations, delivery.File.Path)
+	}
 
-		bucket := handler.awsConf.bucket
-		if request.Delivery.S3.Bucket != "" {
-			bucket = &request.Delivery.S3.Bucket
+	if slices.Contains(targets, deliveryTargetSftp) {
+		sftpPublisher, err := sftpPublisherFromConfig(handler.conf, handler.secretsManager, handler.logger)
+		if err != nil {
+			return publisher, err
+		}
+		if sftpPublisher == nil {
+			return publisher, fmt.Errorf("Delivery target %s is not defined in config", deliveryTargetSftp)
 		}
+		publisher = append(publisher, sftpPublisher)
+		locations = append(locations, sftpPublisher.location())
+	}
 
-		basePath := handler.awsConf.basePath
-		if request.Delivery.S3.Path != "" {
-			basePath = &request.Delivery.S3.Path
+	if slices.Contains(targets, deliveryTargetWebhook) {
+		webhookPublisher, err := webhookPublisherFromConfig(handler.conf, handler.secretsManager, handler.logger)
+		if err != nil {
+			return publisher, err
 		}
-		publisher = append(publisher, timetracker.NewS3Publisher(region, bucket, basePath, handler.logger))
+		if webhookPublisher == nil {
+			return publisher, fmt.Errorf("Delivery target %s is not defined in config", deliveryTargetWebhook)
+		}
+		publisher = append(publisher, webhookPublisher)
 	}
 
-	if request.Delivery.File != nil {
-		publisher = append(publisher, timetracker.NewFilePublisher(&request.Delivery.File.Path, handler.logger))
+	// Chat notifiers have to be added at last to get only files which have been published to all other targets.
+	for _, channel := range request.Chat.Channels {
+		notifier, err := handler.newChatNotifier(channel, locations)
+		if err != nil {
+			return publisher, err
+		}
+		publisher = append(publisher, notifier)
 	}
 
 	if len(publisher) > 0 {
 		return publisher, nil
 
 	} else {
-		return publisher, errors.New("No report delivery defined!")
+		return publisher, errNoReportDelivery
+	}
+}
+
+// S3Target returns region, bucket and base path of given S3 delivery target. Values from AWS config are used for all
+// settings which are not part of a target.
+func (handler *ReportGenerator) s3Target(target *core.S3Target) (*string, *string, *string) {
+
+	region := handler.awsConf.region
+	if target.Region != "" {
+		region = &target.Region
+	}
+
+	bucket := handler.awsConf.bucket
+	if target.Bucket != "" {
+		bucket = &target.Bucket
+	}
+
+	basePath := handler.awsConf.basePath
+	if target.Path != "" {
+		basePath = &target.Path
+	}
+	return region, bucket, basePath
+}
+
+// NewChatNotifier returns a notifier for given chat channel. Incoming webhook url of a channel is obtained by
+// secrets manager with key defined in config, a message template can be defined for each channel.
+//
+//	chat:
+//	  timeout: 10s
+//	  template: Time Tracking Report {{ .Period }}
+//	  channels:
+//	    - name: team
+//	      secret: HOB_CHAT_TEAM_WEBHOOK
+func (handler *ReportGenerator) newChatNotifier(channel string, locations []string) (*chatNotifier, error) {
+
+	for _, channelConf := range handler.conf.GetAsSliceOfMaps("hob.chat.channels") {
+		if channelConf["name"] != channel {
+			continue
+		}
+		if handler.secretsManager == nil {
+			return nil, errors.New("No secrets manager available to obtain chat webhook url")
+		}
+		url, err := handler.secretsManager.Obtain(channelConf["secret"])
+		if err != nil {
+			return nil, err
+		}
+		template := *handler.conf.Get("hob.chat.template", config.AsStringPtr(defaultChatTemplate))
+		if channelTemplate, ok := channelConf["template"]; ok {
+			template = channelTemplate
+		}
+		chatTemplate, err := newChatTemplate(template)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid chat template for channel %s, reason: %s", channel, err)
+		}
+		timeout := handler.conf.GetAsDuration("hob.chat.timeout", config.AsDurationPtr(10*time.Second))
+		return newChatNotifier(*url, chatTemplate, locations, *timeout, handler.logger), nil
+	}
+	return nil, fmt.Errorf("Unknown chat channel: %s", channel)
+}
+
+// MailRecipients returns all valid To addresses of a request together with CC and BCC addresses
+// and a reply address from config. Returns with an error if there's no valid To address.
+func (handler *ReportGenerator) mailRecipients(toAddresses []string) (mailRecipients, error) {
+
+	recipients := mailRecipients{
+		to:  validAddresses(toAddresses, handler.logger),
+		cc:  validAddresses(emailAddresses(handler.conf, "hob.email.cc"), handler.logger),
+		bcc: validAddresses(emailAddresses(handler.conf, "hob.email.bcc"), handler.logger),
+	}
+	if replyTo := handler.conf.Get("hob.email.replyto", nil); replyTo != nil {
+		if replyToAddresses := validAddresses([]string{*replyTo}, handler.logger); len(replyToAddresses) > 0 {
+			recipients.replyTo = replyToAddresses[0]
+		}
+	}
+	if len(recipients.to) == 0 {
+		return recipients, fmt.Errorf("No valid email address in %s", strings.Join(toAddresses, ", "))
+	}
+	return recipients, nil
+}
+
+// Error returns a list of all failed formats together with their errors.
+func (errs formatErrors) Error() string {
+	messages := []string{}
+	for format, err := range errs {
+		messages = append(messages, format+": "+err.Error())
+	}
+	sort.Strings(messages)
+	return "Report generation failed for " + strings.Join(messages, ", ")
+}
+
+// Error returns a message with all devices records couldn't be fetched for.
+func (errs deviceErrors) Error() string {
+	messages := []string{}
+	for deviceId, err := range errs {
+		messages = append(messages, deviceId+": "+err.Error())
 	}
+	sort.Strings(messages)
+	return "Unable to fetch time tracking records for " + strings.Join(messages, ", ")
 }
 
+// RunConcurrently calls given task for each index from 0 to count - 1. Up to passed number of workers,
+// at least one, tasks are running at the same time. Returns after all tasks have been finished.
+func runConcurrently(count, workers int, task func(idx int)) {
+
+	if workers < 1 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < count; idx++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(idx int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			task(idx)
+		}(idx)
+	}
+	wg.Wait()
+}
+
+// UnwrapAwsEventBridgeTrigger returns report request content of an event, which may have been wrapped by an EventBridge trigger.
 func unwrapAwsEventBridgeTrigger(messageBody string) string {
 	trigger := awsEventBridgeTrigger{}
 	if err := json.Unmarshal(([]byte(messageBody)), &trigger); err == nil && len(trigger.Content) > 0 {
@@ -200,3 +1063,12 @@ func unwrapAwsEventBridgeTrigger(messageBody string) string {
 	}
 	return messageBody
 }
+
+// UnwrapReportOptions returns additional report options which are passed together with a wrapped report request.
+func unwrapReportOptions(messageBody string) reportOptions {
+	trigger := awsEventBridgeTrigger{}
+	if err := json.Unmarshal(([]byte(messageBody)), &trigger); err == nil {
+		return trigger.Options
+	}
+	return reportOptions{}
+}
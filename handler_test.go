@@ -2,6 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,37 +18,583 @@ import (
 
 type HandlerTestSuite struct {
 	suite.Suite
+
+	// Path is a temporary directory all reports are written to.
+	path string
 }
 
 func TestHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(HandlerTestSuite))
 }
 
+func (suite *HandlerTestSuite) SetupTest() {
+	suite.path = suite.T().TempDir() + "/"
+}
+
 func (suite *HandlerTestSuite) TestGenerateReport() {
 
 	handler := suite.handlerForTest()
-	event := suite.sqsEventForTest(eventForTest())
+	event := suite.sqsEventForTest(eventForTest(suite.path))
 
-	suite.Nil(handler.HandleEvents(context.Background(), event))
+	suite.assertBatchItemFailures(handler, event)
 
 	event.Records[0].Body = "xxx"
-	suite.NotNil(handler.HandleEvents(context.Background(), event))
+	suite.assertBatchItemFailures(handler, event, "<ID>")
 
-	event2 := suite.sqsEventForTest(eventWithInvalidFormatterForTest())
-	suite.NotNil(handler.HandleEvents(context.Background(), event2))
+	event2 := suite.sqsEventForTest(eventWithInvalidFormatterForTest(suite.path))
+	suite.assertBatchItemFailures(handler, event2, "<ID>")
 
 	event3 := suite.sqsEventForTest(eventWithoutDeliveryForTest())
-	suite.NotNil(handler.HandleEvents(context.Background(), event3))
+	suite.assertBatchItemFailures(handler, event3, "<ID>")
+
+	event4 := suite.sqsEventForTest(eventWithInvalidTypeForTest(suite.path))
+	suite.assertBatchItemFailures(handler, event4, "<ID>")
+}
+
+func (suite *HandlerTestSuite) TestHandleEventsWithPartialFailure() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventForTest(eventForTest(suite.path))
+	invalidEvent := suite.sqsEventForTest(eventWithInvalidFormatterForTest(suite.path))
+	event.Records = append(event.Records, invalidEvent.Records[0], event.Records[0], events.SQSMessage{Body: "xxx"})
+	event.Records[1].MessageId = "<ID2>"
+	event.Records[2].MessageId = "<ID3>"
+	event.Records[3].MessageId = "<ID4>"
+
+	suite.assertBatchItemFailures(handler, event, "<ID2>", "<ID4>")
+}
+
+func (suite *HandlerTestSuite) TestHandleEventsConcurrently() {
+
+	path := suite.T().TempDir() + "/"
+	handler := suite.handlerForTest()
+	handler.batchWorkers = 4
+
+	event := suite.sqsEventForMonthsForTest(path, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	event.Records[5].Body = "xxx"
+
+	suite.assertBatchItemFailures(handler, event, "<ID6>")
+	for month := 1; month <= 12; month++ {
+		if month != 6 {
+			suite.FileExists(fmt.Sprintf("%sTestReport_2022%02d.xlsx", path, month))
+		}
+	}
+}
+
+func (suite *HandlerTestSuite) TestHandleEventsWithoutDelivery() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventForMonthsForTest(suite.path, 1)
+	request := eventForTest(suite.path)
+	request.Delivery = nil
+	message := suite.sqsEventForTest(request).Records[0]
+	message.MessageId = "<ID2>"
+	event.Records = append(event.Records, message)
+
+	suite.assertBatchItemFailures(handler, event, "<ID2>")
+	suite.FileExists(suite.path + "TestReport_202201.xlsx")
+}
+
+func (suite *HandlerTestSuite) TestHandleEventsRecoversFromPanic() {
+
+	path := suite.T().TempDir() + "/"
+	handler := suite.handlerForTest()
+	handler.batchWorkers = 2
+	handler.calendar = &panicCalendarMock{month: 2}
+
+	suite.assertBatchItemFailures(handler, suite.sqsEventForMonthsForTest(path, 1, 2, 3), "<ID2>")
+	suite.FileExists(path + "TestReport_202201.xlsx")
+	suite.NoFileExists(path + "TestReport_202202.xlsx")
+	suite.FileExists(path + "TestReport_202203.xlsx")
+}
+
+func (suite *HandlerTestSuite) TestDeferMessagesIfDeadlineIsReached() {
+
+	path := suite.T().TempDir() + "/"
+	handler := suite.handlerForTest()
+	handler.safetyMargin = 5 * time.Second
+	event := suite.sqsEventForMonthsForTest(path, 1, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err := handler.HandleEvents(ctx, event)
+	suite.Nil(err)
+	suite.Len(response.BatchItemFailures, 2)
+	suite.NoFileExists(path + "TestReport_202201.xlsx")
+
+	response, err = handler.HandleEvents(context.Background(), event)
+	suite.Nil(err)
+	suite.Len(response.BatchItemFailures, 0)
+	suite.FileExists(path + "TestReport_202201.xlsx")
+}
+
+func (suite *HandlerTestSuite) TestDeferMessagesIfDeadlineIsApproaching() {
+
+	path := suite.T().TempDir() + "/"
+	handler := suite.handlerForTest()
+	handler.safetyMargin = time.Second
+	handler.fetchWorkers = 3
+	handler.timeTracker = &timeTrackerMock{TimeTracker: handler.timeTracker, delay: 600 * time.Millisecond}
+	event := suite.sqsEventForMonthsForTest(path, 1, 2, 3)
+
+	// Second message starts before safety margin is reached, but can't be completed in time.
+	// Third message is deferred afterwards.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	response, err := handler.HandleEvents(ctx, event)
+	suite.Nil(err)
+	suite.Equal([]events.SQSBatchItemFailure{{ItemIdentifier: "<ID2>"}, {ItemIdentifier: "<ID3>"}}, response.BatchItemFailures)
+	suite.FileExists(path + "TestReport_202201.xlsx")
+	suite.NoFileExists(path + "TestReport_202202.xlsx")
+}
+
+func (suite *HandlerTestSuite) TestFailMessagesWhichExceedDeadline() {
+
+	path := suite.T().TempDir() + "/"
+	handler := suite.handlerForTest()
+	handler.batchWorkers = 3
+	handler.safetyMargin = time.Second
+	handler.timeTracker = &timeTrackerMock{TimeTracker: handler.timeTracker, delays: map[string]time.Duration{"Device02": 3 * time.Second}}
+
+	event := events.SQSEvent{}
+	for idx, deviceId := range []string{"Device01", "Device02", "Device03"} {
+		request := eventForTest(path)
+		request.Month = int64(idx + 1)
+		request.DeviceIds = []string{deviceId}
+		message := suite.sqsEventForTest(request).Records[0]
+		message.MessageId = "<" + deviceId + ">"
+		event.Records = append(event.Records, message)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	response, err := handler.HandleEvents(ctx, event)
+	suite.Nil(err)
+	suite.Less(time.Since(start), 2*time.Second)
+	suite.Equal([]events.SQSBatchItemFailure{{ItemIdentifier: "<Device02>"}}, response.BatchItemFailures)
+	suite.FileExists(path + "TestReport_202201.xlsx")
+	suite.NoFileExists(path + "TestReport_202202.xlsx")
+	suite.FileExists(path + "TestReport_202203.xlsx")
+}
+
+func (suite *HandlerTestSuite) TestSkipCompletedRequests() {
+
+	path := suite.T().TempDir() + "/"
+	reportFile := path + "TestReport_202201.xlsx"
+	store := newMemoryIdempotencyStore()
+	handler := reportGeneratorForTest()
+	handler.idempotencyStore = store
+	handler.idempotencyTtl = time.Hour
+
+	request := eventForTest(suite.path)
+	request.Delivery.File.Path = path
+
+	suite.assertBatchItemFailures(handler, suite.sqsEventForTest(request))
+	suite.FileExists(reportFile)
+	suite.Len(store.completed, 1)
+
+	suite.Nil(os.Remove(reportFile))
+	suite.assertBatchItemFailures(handler, suite.sqsEventForTest(request))
+	suite.NoFileExists(reportFile)
+
+	suite.assertBatchItemFailures(handler, suite.sqsEventWithOptionsForTest(request, reportOptions{Force: true}))
+	suite.FileExists(reportFile)
+
+	suite.Nil(os.Remove(reportFile))
+	for key := range store.completed {
+		store.completed[key] = time.Now().Add(-2 * time.Hour)
+	}
+	suite.assertBatchItemFailures(handler, suite.sqsEventForTest(request))
+	suite.FileExists(reportFile)
+}
+
+func (suite *HandlerTestSuite) TestFailedRequestsAreNotCompleted() {
+
+	store := newMemoryIdempotencyStore()
+	handler := reportGeneratorForTest()
+	handler.idempotencyStore = store
+	handler.idempotencyTtl = time.Hour
+
+	event := suite.sqsEventForTest(eventWithoutDeliveryForTest())
+	suite.assertBatchItemFailures(handler, event, "<ID>")
+	suite.assertBatchItemFailures(handler, event, "<ID>")
+	suite.Len(store.completed, 0)
+}
+
+func (suite *HandlerTestSuite) TestGenerateWeeklyReport() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventWithOptionsForTest(eventWithWeeklyTypeForTest(suite.path), reportOptions{Week: 5})
+	suite.assertBatchItemFailures(handler, event)
+
+	event2 := suite.sqsEventForTest(eventWithWeeklyTypeForTest(suite.path))
+	suite.assertBatchItemFailures(handler, event2)
+}
+
+func (suite *HandlerTestSuite) TestGenerateYearlyReport() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventForTest(eventWithYearlyTypeForTest(suite.path))
+	suite.assertBatchItemFailures(handler, event)
+}
+
+func (suite *HandlerTestSuite) TestCalculateYearlyReport() {
+
+	pipeline := suite.pipelineForTest()
+	pipeline.calculator.WithTimeTrackingRecords([]timetracker.TimeTrackingRecord{
+		{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 8, 0, 0, 0, time.UTC)},
+		{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 18, 0, 0, 0, time.UTC)},
+	})
+
+	report, _, err := pipeline.calculateYearlyReport(2022)
+	suite.Nil(err)
+	suite.Len(report.Months, 12)
+	suite.Equal(21, report.Months[0].Workdays)
+	suite.Equal(9*time.Hour+15*time.Minute, report.Months[0].WorkingTime)
+	suite.Equal(21*8*time.Hour, report.Months[0].ExpectedWorkingTime)
+	suite.Equal(report.Months[0].WorkingTime-report.Months[0].ExpectedWorkingTime, report.Months[0].Overtime)
+	suite.Equal(report.Months[0].Overtime+report.Months[1].Overtime, report.Months[1].OvertimeBalance)
+	suite.Equal(report.Overtime, report.Months[11].OvertimeBalance)
+	suite.Equal(report.TotalWorkingTime-report.ExpectedWorkingTime, report.Overtime)
+}
+
+func (suite *HandlerTestSuite) TestListRecords() {
+
+	pipeline := suite.pipelineForTest()
+	start := time.Date(2022, 1, 3, 8, 0, 0, 0, time.UTC)
+	deviceIds := []string{"Device01", "Device02", "Device03"}
+	for _, deviceId := range deviceIds {
+		suite.Nil(pipeline.timeTracker.Captured(deviceId, timetracker.WORKDAY, start))
+		suite.Nil(pipeline.timeTracker.Captured(deviceId, timetracker.WORKDAY, start.Add(8*time.Hour)))
+	}
+
+	records, err := pipeline.listRecords(deviceIds, start.AddDate(0, 0, -1), start.AddDate(0, 0, 1))
+	suite.Nil(err)
+	suite.Len(records, 6)
+	suite.Equal("Device01", records[0].DeviceId)
+	suite.Equal("Device03", records[5].DeviceId)
+}
+
+func (suite *HandlerTestSuite) TestListRecordsWithFailedDevices() {
+
+	pipeline := suite.pipelineForTest()
+	pipeline.timeTracker = &timeTrackerMock{
+		TimeTracker: pipeline.timeTracker,
+		errors:      map[string]error{"Device02": errors.New("Access denied"), "Device03": errors.New("Timeout")},
+	}
+	start, end := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	records, err := pipeline.listRecords([]string{"Device01", "Device02", "Device03"}, start, end)
+	suite.Nil(records)
+	suite.IsType(deviceErrors{}, err)
+	suite.Len(err.(deviceErrors), 2)
+	suite.Equal("Unable to fetch time tracking records for Device02: Access denied, Device03: Timeout", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestListRecordsStopsIfContextIsDone() {
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	pipeline := suite.pipelineForTest()
+	pipeline.ctx = ctx
+	pipeline.timeTracker = &timeTrackerMock{TimeTracker: pipeline.timeTracker, blocked: blocked}
+	start, end := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	records, err := pipeline.listRecords([]string{"Device01", "Device02", "Device03"}, start, end)
+	suite.Nil(records)
+	suite.Len(err.(deviceErrors), 3)
+	suite.Equal(context.DeadlineExceeded, err.(deviceErrors)["Device03"])
+
+	request := &reportRequest{GenerateReportRequest: eventForTest(suite.path)}
+	suite.NotNil(suite.handlerForTest().processRequest(ctx, request))
+}
+
+func (suite *HandlerTestSuite) TestNewMonthlySummary() {
+
+	report := &timetracker.MonthlyReport{
+		Year:     2022,
+		Month:    5,
+		Location: timetracker.Locale{DefaultWorkTime: 8 * time.Hour},
+		Days: []timetracker.Day{
+			{Date: timetracker.Date{Year: 2022, Month: 5, Day: 2}, Type: timetracker.WORKDAY, WorkingTime: 10 * time.Hour},
+			{Date: timetracker.Date{Year: 2022, Month: 5, Day: 3}, Type: timetracker.VACATION},
+		},
+		TotalWorkingTime: 10 * time.Hour,
+	}
+	holidays := []timetracker.Holiday{{Date: timetracker.Date{Year: 2022, Month: 5, Day: 26}, Description: "Ascension Day"}}
+
+	summary := newMonthlySummary(report, holidays)
+	suite.Equal(5, summary.Month)
+	suite.Equal(20, summary.Workdays)
+	suite.Equal(160*time.Hour, summary.ExpectedWorkingTime)
+	suite.Equal(-150*time.Hour, summary.Overtime)
+}
+
+func (suite *HandlerTestSuite) TestNewReportSummary() {
+
+	report := &generatedReport{DeviceIds: []string{"Device01"}, Report: periodReportForTest()}
+	summary := newReportSummary(report)
+	suite.Equal("2021-12-27 - 2022-01-02", summary.Period)
+	suite.Equal([]string{"Device01"}, summary.Devices)
+	suite.Equal(1, summary.Workdays)
+	suite.Equal(report.Report.(*periodReport).TotalWorkingTime, summary.TotalWorkingTime)
+	suite.Equal(summary.TotalWorkingTime-summary.ExpectedWorkingTime, summary.Overtime)
+
+	yearly := &generatedReport{Report: &yearlyReport{Year: 2022, Months: []monthlySummary{{Workdays: 20}, {Workdays: 19}}, TotalWorkingTime: 300 * time.Hour, ExpectedWorkingTime: 312 * time.Hour}}
+	yearlySummary := newReportSummary(yearly)
+	suite.Equal("2022", yearlySummary.Period)
+	suite.Equal(39, yearlySummary.Workdays)
+	suite.Equal(-12*time.Hour, yearlySummary.Overtime)
+}
+
+func (suite *HandlerTestSuite) TestGenerateDateRangeReport() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventWithOptionsForTest(eventWithDateRangeTypeForTest(suite.path), reportOptions{StartDate: "2021-12-16", EndDate: "2022-01-15"})
+	suite.assertBatchItemFailures(handler, event)
+
+	event2 := suite.sqsEventWithOptionsForTest(eventWithDateRangeTypeForTest(suite.path), reportOptions{StartDate: "2022-01-15", EndDate: "2021-12-16"})
+	suite.assertBatchItemFailures(handler, event2, "<ID>")
+
+	event3 := suite.sqsEventForTest(eventWithDateRangeTypeForTest(suite.path))
+	suite.assertBatchItemFailures(handler, event3, "<ID>")
+}
+
+func (suite *HandlerTestSuite) TestGetDateRangeReportTimeRange() {
+
+	request := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{}, reportOptions: reportOptions{StartDate: "2022-01-16", EndDate: "2022-02-15"}}
+	start1, end1, err1 := dateRangeReportTimeRange(request, 31, time.UTC)
+	suite.Nil(err1)
+	suite.Equal(time.Date(2022, 1, 16, 0, 0, 0, 0, time.UTC), start1)
+	suite.Equal(time.Date(2022, 2, 15, 23, 59, 59, 0, time.UTC), end1)
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	request.StartDate, request.EndDate = "2022-03-20", "2022-04-05"
+	start6, end6, err6 := dateRangeReportTimeRange(request, 17, berlin)
+	suite.Nil(err6)
+	suite.Equal(time.Date(2022, 3, 19, 23, 0, 0, 0, time.UTC), start6.UTC())
+	suite.Equal(time.Date(2022, 4, 5, 21, 59, 59, 0, time.UTC), end6.UTC())
+	request.StartDate, request.EndDate = "2022-01-16", "2022-02-15"
+
+	_, _, err2 := dateRangeReportTimeRange(request, 30, time.UTC)
+	suite.NotNil(err2)
+
+	request.EndDate = "2022-01-16"
+	_, _, err3 := dateRangeReportTimeRange(request, 31, time.UTC)
+	suite.NotNil(err3)
+
+	request.EndDate = "15.02.2022"
+	_, _, err4 := dateRangeReportTimeRange(request, 31, time.UTC)
+	suite.NotNil(err4)
+
+	request.StartDate = ""
+	request.EndDate = "2022-02-15"
+	_, _, err5 := dateRangeReportTimeRange(request, 31, time.UTC)
+	suite.NotNil(err5)
+}
+
+func (suite *HandlerTestSuite) TestReportFileName() {
+
+	start := time.Date(2021, 12, 16, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 1, 15, 23, 59, 59, 0, time.UTC)
+	suite.Equal("Report_202112", reportFileName("Report_200601", start, end))
+	suite.Equal("Report_20211216-20220115", reportFileName("Report_20060102-{end:20060102}", start, end))
+	suite.Equal("Report_2021_W50", reportFileName("Report_2006_W{week}", start, end))
+	suite.Equal("", reportFileName("", start, end))
+}
+
+func (suite *HandlerTestSuite) TestGetYearlyReportTimeRange() {
+
+	start1, end1 := yearlyReportTimeRange(&core.GenerateReportRequest{Year: 2022}, time.UTC)
+	suite.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), start1)
+	suite.Equal(time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC), end1)
+
+	start2, end2 := yearlyReportTimeRange(&core.GenerateReportRequest{}, time.UTC)
+	suite.Equal(time.Now().Year()-1, start2.Year())
+	suite.Equal(time.Now().Year()-1, end2.Year())
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	start3, end3 := yearlyReportTimeRange(&core.GenerateReportRequest{Year: 2022}, berlin)
+	suite.Equal(time.Date(2021, 12, 31, 23, 0, 0, 0, time.UTC), start3.UTC())
+	suite.Equal(time.Date(2022, 12, 31, 22, 59, 59, 0, time.UTC), end3.UTC())
+}
+
+func (suite *HandlerTestSuite) TestGetWeeklyReportTimeRange() {
+
+	start1, end1, err1 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Year: 2021}, reportOptions: reportOptions{Week: 1}}, time.UTC)
+	suite.Nil(err1)
+	suite.Equal(time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), start1)
+	suite.Equal(time.Date(2021, 1, 10, 23, 59, 59, 0, time.UTC), end1)
+
+	start2, end2, err2 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Year: 2020}, reportOptions: reportOptions{Week: 53}}, time.UTC)
+	suite.Nil(err2)
+	suite.Equal(time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), start2)
+	suite.Equal(time.Date(2021, 1, 3, 23, 59, 59, 0, time.UTC), end2)
+
+	start3, end3, err3 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{}}, time.UTC)
+	suite.Nil(err3)
+	_, thisWeek := time.Now().ISOWeek()
+	_, lastWeek := start3.ISOWeek()
+	suite.Equal(time.Monday, start3.Weekday())
+	suite.Equal(time.Sunday, end3.Weekday())
+	suite.NotEqual(thisWeek, lastWeek)
+	suite.True(end3.Before(time.Now()))
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	start4, end4, err4 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Year: 2022}, reportOptions: reportOptions{Week: 12}}, berlin)
+	suite.Nil(err4)
+	suite.Equal(time.Date(2022, 3, 20, 23, 0, 0, 0, time.UTC), start4.UTC())
+	suite.Equal(time.Date(2022, 3, 27, 21, 59, 59, 0, time.UTC), end4.UTC())
+
+	_, _, err5 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Year: 2021}, reportOptions: reportOptions{Week: 53}}, time.UTC)
+	suite.NotNil(err5)
+
+	_, _, err6 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Year: 2021}, reportOptions: reportOptions{Week: 54}}, time.UTC)
+	suite.NotNil(err6)
+
+	_, _, err7 := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: &core.GenerateReportRequest{}, reportOptions: reportOptions{Week: 12}}, time.UTC)
+	suite.NotNil(err7)
+}
+
+func (suite *HandlerTestSuite) TestGenerateReportInMultipleFormats() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventWithOptionsForTest(eventForTest(suite.path), reportOptions{Formats: []string{"csv", "PDF", "excel"}})
+	suite.assertBatchItemFailures(handler, event)
+
+	pipeline, err := handler.newPipeline(context.Background(), &reportRequest{GenerateReportRequest: eventForTest(suite.path), reportOptions: reportOptions{Formats: []string{"csv", "PDF", "excel"}}})
+	suite.Nil(err)
+	suite.Len(pipeline.formatter, 3)
+
+	event2 := suite.sqsEventWithOptionsForTest(eventForTest(suite.path), reportOptions{Formats: []string{"docx"}})
+	suite.assertBatchItemFailures(handler, event2, "<ID>")
+}
+
+func (suite *HandlerTestSuite) TestFormatAndPublishWithPartialFailure() {
+
+	pipeline := suite.pipelineForTest()
+	publisher := &publisherMock{}
+	pipeline.formatter = []timetracker.ReportFormatter{timetracker.NewExcelReportFormatter(loggerForTest()), newCsvReportFormatter(loggerForTest())}
+	pipeline.publisher = []timetracker.ReportPublisher{publisher}
+	request := &reportRequest{GenerateReportRequest: eventWithWeeklyTypeForTest(suite.path)}
+	report := &generatedReport{Start: time.Now(), End: time.Now(), Report: periodReportForTest()}
+
+	err := pipeline.formatAndPublish(request, report)
+	suite.NotNil(err)
+	suite.IsType(formatErrors{}, err)
+	suite.Len(err.(formatErrors), 1)
+	suite.Contains(err.Error(), ".xlsx: ")
+	suite.Len(publisher.files, 1)
+	suite.True(strings.HasSuffix(publisher.files[0], ".csv"))
+}
+
+func (suite *HandlerTestSuite) TestFormatAndPublishAsArchive() {
+
+	pipeline := suite.pipelineForTest()
+	publisher := &publisherMock{}
+	pipeline.formatter = []timetracker.ReportFormatter{newCsvReportFormatter(loggerForTest()), newJsonReportFormatter(loggerForTest())}
+	pipeline.publisher = []timetracker.ReportPublisher{publisher}
+	request := &reportRequest{GenerateReportRequest: eventWithWeeklyTypeForTest(suite.path), reportOptions: reportOptions{Archive: true}}
+	report := &generatedReport{Start: time.Now(), End: time.Now(), Report: periodReportForTest()}
+
+	suite.Nil(pipeline.formatAndPublish(request, report))
+	suite.Len(publisher.files, 1)
+	suite.True(strings.HasSuffix(publisher.files[0], ".zip"))
+}
 
-	event4 := suite.sqsEventForTest(eventWithInvalidTypeForTest())
-	suite.NotNil(handler.HandleEvents(context.Background(), event4))
+func (suite *HandlerTestSuite) TestFormatAndPublishCompletesDelivery() {
+
+	pipeline := suite.pipelineForTest()
+	publisher := &completingPublisherMock{}
+	pipeline.formatter = []timetracker.ReportFormatter{newCsvReportFormatter(loggerForTest()), newJsonReportFormatter(loggerForTest())}
+	pipeline.publisher = []timetracker.ReportPublisher{publisher}
+	request := &reportRequest{GenerateReportRequest: eventWithWeeklyTypeForTest(suite.path)}
+	report := &generatedReport{Start: time.Now(), End: time.Now(), Report: periodReportForTest()}
+
+	suite.Nil(pipeline.formatAndPublish(request, report))
+	suite.Len(publisher.files, 2)
+	suite.Equal(1, publisher.completed)
+
+	publisher.err = errors.New("Test Error")
+	suite.NotNil(pipeline.formatAndPublish(request, report))
+	suite.Equal(1, publisher.completed)
+}
+
+func (suite *HandlerTestSuite) TestFormatAndPublishWithSingleEmail() {
+
+	pipeline := suite.pipelineForTest()
+	client := &sesClientMock{}
+	publisher := newMailPublisher("from@example.com", mailRecipients{to: []string{"to@example.com"}}, mailTemplatesForTest(), &sesTransport{client: client}, mailOptions{}, loggerForTest())
+	pipeline.formatter = []timetracker.ReportFormatter{newCsvReportFormatter(loggerForTest()), newJsonReportFormatter(loggerForTest())}
+	pipeline.publisher = []timetracker.ReportPublisher{publisher}
+	request := &reportRequest{GenerateReportRequest: eventWithWeeklyTypeForTest(suite.path)}
+	report := &generatedReport{Start: time.Now(), End: time.Now(), Report: periodReportForTest()}
+
+	suite.Nil(pipeline.formatAndPublish(request, report))
+	suite.Len(client.inputs, 1)
+	rawEMail := string(client.inputs[0].RawMessage.Data)
+	suite.Contains(rawEMail, ".csv\"")
+	suite.Contains(rawEMail, ".json\"")
+}
+
+func (suite *HandlerTestSuite) TestReportFormats() {
+
+	request := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Format: core.ReportFormat_EXCEL}}
+	formats1, err1 := request.reportFormats()
+	suite.Nil(err1)
+	suite.Equal([]core.ReportFormat{core.ReportFormat_EXCEL}, formats1)
+
+	request.Formats = []string{"json", "Excel", "JSON"}
+	formats2, err2 := request.reportFormats()
+	suite.Nil(err2)
+	suite.Equal([]core.ReportFormat{core.ReportFormat_EXCEL, reportFormatJson}, formats2)
+
+	request.Formats = []string{"xml"}
+	_, err3 := request.reportFormats()
+	suite.NotNil(err3)
+
+	request = &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{Format: core.ReportFormat_NO_FORMAT}}
+	formats4, err4 := request.reportFormats()
+	suite.Nil(err4)
+	suite.Equal([]core.ReportFormat{core.ReportFormat_NO_FORMAT}, formats4)
+
+	request.Formats = []string{"csv", "json"}
+	formats5, err5 := request.reportFormats()
+	suite.Nil(err5)
+	suite.Equal([]core.ReportFormat{reportFormatCsv, reportFormatJson}, formats5)
+}
+
+func (suite *HandlerTestSuite) TestReportWithFormatsFromOptionsOnly() {
+
+	handler := suite.handlerForTest()
+	event := suite.sqsEventWithOptionsForTest(eventWithInvalidFormatterForTest(suite.path), reportOptions{Formats: []string{"csv"}})
+
+	suite.assertBatchItemFailures(handler, event)
+	suite.FileExists(suite.path + "TestReport_202201.csv")
+	suite.NoFileExists(suite.path + "TestReport_202201.xlsx")
+}
+
+func (suite *HandlerTestSuite) TestFormatReport() {
+
+	_, err1 := formatReport(newCsvReportFormatter(loggerForTest()), periodReportForTest())
+	suite.Nil(err1)
+
+	_, err2 := formatReport(timetracker.NewExcelReportFormatter(loggerForTest()), periodReportForTest())
+	suite.NotNil(err2)
+
+	_, err3 := formatReport(newCsvReportFormatter(loggerForTest()), "report")
+	suite.NotNil(err3)
 }
 
 func (suite *HandlerTestSuite) TestGetReportTimeRange() {
 
 	year := 2022
 	month := 1
-	start1, end1 := reportTimeRange(&core.GenerateReportRequest{Year: int64(year), Month: int64(month)})
+	start1, end1 := reportTimeRange(&core.GenerateReportRequest{Year: int64(year), Month: int64(month)}, time.UTC)
 	suite.Equal(1, start1.Day())
 	suite.Equal(31, end1.Day())
 	suite.Equal(month, int(start1.Month()))
@@ -51,9 +602,14 @@ func (suite *HandlerTestSuite) TestGetReportTimeRange() {
 	suite.Equal(year, start1.Year())
 	suite.Equal(year, end1.Year())
 
-	start2, end2 := reportTimeRange(&core.GenerateReportRequest{})
+	start2, end2 := reportTimeRange(&core.GenerateReportRequest{}, time.UTC)
 	suite.Equal(1, start2.Day())
 	suite.True(end2.Day() >= 28)
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	start3, end3 := reportTimeRange(&core.GenerateReportRequest{Year: 2022, Month: 10}, berlin)
+	suite.Equal(time.Date(2022, 9, 30, 22, 0, 0, 0, time.UTC), start3.UTC())
+	suite.Equal(time.Date(2022, 10, 31, 22, 59, 59, 0, time.UTC), end3.UTC())
 }
 
 func (suite *HandlerTestSuite) TestUnwrapAwsEventBridgeTrigger() {
@@ -67,20 +623,31 @@ func (suite *HandlerTestSuite) TestUnwrapAwsEventBridgeTrigger() {
 }
 
 func (suite *HandlerTestSuite) handlerForTest() *ReportGenerator {
+	return reportGeneratorForTest()
+}
+
+func (suite *HandlerTestSuite) pipelineForTest() *reportPipeline {
+	handler := suite.handlerForTest()
+	return &reportPipeline{ReportGenerator: handler, ctx: context.Background(), calculator: newReportCalulator(handler.locale)}
+}
+
+// ReportGeneratorForTest returns a report generator with test config and a local time tracker.
+func reportGeneratorForTest() *ReportGenerator {
 
 	conf := configForTest()
 	locale := newLocale(conf)
 	logger := loggerForTest()
 
 	deviceIds := deviceIds(conf)
-	calculator := newReportCalulator(locale)
 
 	return &ReportGenerator{
-		awsConf:     awsConfig{},
-		logger:      logger,
-		deviceIds:   deviceIds,
-		timeTracker: timeTrackeForTest(),
-		calculator:  calculator,
+		awsConf:      awsConfig{},
+		conf:         conf,
+		logger:       logger,
+		deviceIds:    deviceIds,
+		timeTracker:  timeTrackeForTest(),
+		locale:       locale,
+		fetchWorkers: 2,
 	}
 }
 
@@ -97,6 +664,17 @@ func timeTrackeForTest() timetracker.TimeTracker {
 	return tracker
 }
 
+// AssertBatchItemFailures processes given event and expects all passed message ids, and only these, as batch item failures.
+func (suite *HandlerTestSuite) assertBatchItemFailures(handler *ReportGenerator, event events.SQSEvent, messageIds ...string) {
+	response, err := handler.HandleEvents(context.Background(), event)
+	suite.Nil(err)
+	failedMessageIds := []string{}
+	for _, failure := range response.BatchItemFailures {
+		failedMessageIds = append(failedMessageIds, failure.ItemIdentifier)
+	}
+	suite.ElementsMatch(messageIds, failedMessageIds)
+}
+
 func (suite *HandlerTestSuite) sqsEventForTest(event *core.GenerateReportRequest) events.SQSEvent {
 	eventData, err := core.SerializeEvent(event)
 	suite.Nil(err)
@@ -111,7 +689,29 @@ func (suite *HandlerTestSuite) sqsEventForTest(event *core.GenerateReportRequest
 	}
 }
 
-func eventForTest() *core.GenerateReportRequest {
+// SqsEventForMonthsForTest returns an event with a message for each passed month of 2022. Message ids are <ID{month}>.
+func (suite *HandlerTestSuite) sqsEventForMonthsForTest(path string, months ...int) events.SQSEvent {
+	event := events.SQSEvent{}
+	for _, month := range months {
+		request := eventForTest(suite.path)
+		request.Month = int64(month)
+		request.Delivery.File.Path = path
+		message := suite.sqsEventForTest(request).Records[0]
+		message.MessageId = fmt.Sprintf("<ID%d>", month)
+		event.Records = append(event.Records, message)
+	}
+	return event
+}
+
+func (suite *HandlerTestSuite) sqsEventWithOptionsForTest(event *core.GenerateReportRequest, options reportOptions) events.SQSEvent {
+	sqsEvent := suite.sqsEventForTest(event)
+	body, err := json.Marshal(awsEventBridgeTrigger{Content: sqsEvent.Records[0].Body, Options: options})
+	suite.Nil(err)
+	sqsEvent.Records[0].Body = string(body)
+	return sqsEvent
+}
+
+func eventForTest(path string) *core.GenerateReportRequest {
 	return &core.GenerateReportRequest{
 		Format:      core.ReportFormat_EXCEL,
 		Type:        core.ReportType_MONTHLY_REPORT,
@@ -120,13 +720,13 @@ func eventForTest() *core.GenerateReportRequest {
 		NamePattern: "TestReport_200601",
 		Delivery: &core.ReportDelivery{
 			File: &core.FileTarget{
-				Path: "./",
+				Path: path,
 			},
 		},
 	}
 }
 
-func eventWithInvalidFormatterForTest() *core.GenerateReportRequest {
+func eventWithInvalidFormatterForTest(path string) *core.GenerateReportRequest {
 	return &core.GenerateReportRequest{
 		Format:      core.ReportFormat_NO_FORMAT,
 		Type:        core.ReportType_MONTHLY_REPORT,
@@ -135,7 +735,7 @@ func eventWithInvalidFormatterForTest() *core.GenerateReportRequest {
 		NamePattern: "TestReport_200601",
 		Delivery: &core.ReportDelivery{
 			File: &core.FileTarget{
-				Path: "./",
+				Path: path,
 			},
 		},
 	}
@@ -152,7 +752,7 @@ func eventWithoutDeliveryForTest() *core.GenerateReportRequest {
 	}
 }
 
-func eventWithInvalidTypeForTest() *core.GenerateReportRequest {
+func eventWithInvalidTypeForTest(path string) *core.GenerateReportRequest {
 	return &core.GenerateReportRequest{
 		Format:      core.ReportFormat_EXCEL,
 		Type:        core.ReportType_NO_TYPE,
@@ -161,8 +761,107 @@ func eventWithInvalidTypeForTest() *core.GenerateReportRequest {
 		NamePattern: "TestReport_200601",
 		Delivery: &core.ReportDelivery{
 			File: &core.FileTarget{
-				Path: "./",
+				Path: path,
+			},
+		},
+	}
+}
+
+func eventWithWeeklyTypeForTest(path string) *core.GenerateReportRequest {
+	return &core.GenerateReportRequest{
+		Format:      core.ReportFormat_EXCEL,
+		Type:        reportTypeWeekly,
+		Year:        2022,
+		NamePattern: "TestReport_2006_W{week}",
+		Delivery: &core.ReportDelivery{
+			File: &core.FileTarget{
+				Path: path,
+			},
+		},
+	}
+}
+
+func eventWithYearlyTypeForTest(path string) *core.GenerateReportRequest {
+	return &core.GenerateReportRequest{
+		Format:      core.ReportFormat_EXCEL,
+		Type:        reportTypeYearly,
+		Year:        2022,
+		NamePattern: "TestReport_2006",
+		Delivery: &core.ReportDelivery{
+			File: &core.FileTarget{
+				Path: path,
 			},
 		},
 	}
 }
+
+func eventWithDateRangeTypeForTest(path string) *core.GenerateReportRequest {
+	return &core.GenerateReportRequest{
+		Format:      core.ReportFormat_EXCEL,
+		Type:        reportTypeDateRange,
+		NamePattern: "TestReport_20060102-{end:20060102}",
+		Delivery: &core.ReportDelivery{
+			File: &core.FileTarget{
+				Path: path,
+			},
+		},
+	}
+}
+
+// PublisherMock collects names of all published files.
+type publisherMock struct {
+	files []string
+	err   error
+}
+
+func (mock *publisherMock) Send(content []byte, fileName string) error {
+	if mock.err != nil {
+		return mock.err
+	}
+	mock.files = append(mock.files, fileName)
+	return nil
+}
+
+// CompletingPublisherMock counts how often a delivery has been completed.
+type completingPublisherMock struct {
+	publisherMock
+	completed int
+}
+
+func (mock *completingPublisherMock) Complete() error {
+	mock.completed++
+	return nil
+}
+
+// TimeTrackerMock returns an error for some devices and blocks fetching records until blocked channel is closed
+// or for given delay.
+type timeTrackerMock struct {
+	timetracker.TimeTracker
+	errors  map[string]error
+	blocked chan struct{}
+	delay   time.Duration
+	delays  map[string]time.Duration
+}
+
+func (mock *timeTrackerMock) ListRecords(deviceId string, start time.Time, end time.Time) ([]timetracker.TimeTrackingRecord, error) {
+	if err, ok := mock.errors[deviceId]; ok {
+		return nil, err
+	}
+	if mock.blocked != nil {
+		<-mock.blocked
+	}
+	time.Sleep(mock.delay + mock.delays[deviceId])
+	return mock.TimeTracker.ListRecords(deviceId, start, end)
+}
+
+// PanicCalendarMock panics if holidays of given month are requested.
+type panicCalendarMock struct {
+	month int
+}
+
+func (mock *panicCalendarMock) GetHolidays(year, month int) ([]timetracker.Holiday, error) {
+	if month == mock.month {
+		panic(fmt.Sprintf("Unexpected month: %d", month))
+	}
+	return []timetracker.Holiday{}, nil
+}
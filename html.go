@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/tommzn/go-log"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+// HtmlReportTemplate renders a report as a styled table. Styles are defined inline
+// because most email clients ignore style sheets.
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{ .Title }}</title></head>
+<body style="font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #222222;">
+<h2 style="font-size: 18px;">{{ .Title }}</h2>
+<p>{{ .Period }}</p>
+<table style="border-collapse: collapse; width: 100%; max-width: 640px;">
+<tr>{{ range .Headlines }}<th style="text-align: left; border-bottom: 2px solid #000000; padding: 4px;">{{ . }}</th>{{ end }}</tr>
+{{- range .Rows }}
+<tr{{ if .Highlight }} style="background-color: #FED7DE;"{{ end }}>{{ range .Values }}<td style="padding: 4px;">{{ . }}</td>{{ end }}</tr>
+{{- end }}
+<tr>{{ range .Totals }}<td style="border-top: 2px solid #000000; padding: 4px; font-weight: bold;">{{ . }}</td>{{ end }}</tr>
+</table>
+</body>
+</html>
+`))
+
+// NewHtmlReportFormatter returns a new formatter to generate HTML files for reports.
+func newHtmlReportFormatter(logger log.Logger) *htmlReportFormatter {
+	return &htmlReportFormatter{
+		holidays:   make(map[timetracker.Date]timetracker.Holiday),
+		dateFormat: "2006-01-02",
+		timeFormat: "15:04",
+		logger:     logger,
+	}
+}
+
+// HtmlReportFormatter generates HTML documents with a table for all days of a report.
+// Output can be used as file or as an email body.
+type htmlReportFormatter struct {
+
+	// Holidays is a list of public holidays, indexed by date.
+	holidays map[timetracker.Date]timetracker.Holiday
+
+	// DateFormat is used for all dates in a report, if there's no date format in report locale.
+	dateFormat string
+
+	// TimeFormat defines output format for timestamps of time tracking records.
+	timeFormat string
+
+	logger log.Logger
+}
+
+// HtmlReport contains all values rendered by html report template.
+type htmlReport struct {
+	Title     string
+	Period    string
+	Headlines []string
+	Rows      []htmlRow
+	Totals    []string
+}
+
+// HtmlRow is a single row of a report table.
+type htmlRow struct {
+	Values    []string
+	Highlight bool
+}
+
+// FileExtension returns file extension for HTML files: html.
+func (formatter *htmlReportFormatter) FileExtension() string {
+	return ".html"
+}
+
+// WithHolidays will assign give list of holidays for output formatting.
+func (formatter *htmlReportFormatter) WithHolidays(holidays []timetracker.Holiday) {
+	formatter.holidays = make(map[timetracker.Date]timetracker.Holiday)
+	for _, holiday := range holidays {
+		formatter.holidays[holiday.Date] = holiday
+	}
+}
+
+// WriteMonthlyReportToFile will generate a report output an writes it to given file.
+func (formatter *htmlReportFormatter) WriteMonthlyReportToFile(report *timetracker.MonthlyReport, filename string) error {
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// WriteMonthlyReportToBuffer returns a HTML document with all days of given monthly report.
+func (formatter *htmlReportFormatter) WriteMonthlyReportToBuffer(report *timetracker.MonthlyReport) (*bytes.Buffer, error) {
+	period := fmt.Sprintf("%04d-%02d", report.Year, report.Month)
+	return formatter.writeDays(period, daysInMonth(report), report.TotalWorkingTime, report.Location)
+}
+
+// WritePeriodReportToBuffer returns a HTML document with all days of given report.
+func (formatter *htmlReportFormatter) WritePeriodReportToBuffer(report *periodReport) (*bytes.Buffer, error) {
+	period := report.Start.Format("2006-01-02") + " - " + report.End.Format("2006-01-02")
+	return formatter.writeDays(period, report.Days, report.TotalWorkingTime, report.Location)
+}
+
+// WriteYearlyReportToBuffer returns a HTML document with a summary of each month of given yearly report.
+func (formatter *htmlReportFormatter) WriteYearlyReportToBuffer(report *yearlyReport) (*bytes.Buffer, error) {
+
+	content := htmlReport{
+		Title:     "Time Tracking Report",
+		Period:    strconv.Itoa(report.Year),
+		Headlines: []string{"Month", "Workdays", "Working Time", "Expected", "Overtime", "Balance"},
+		Rows:      []htmlRow{},
+		Totals:    []string{"Total", "", formatDuration(report.TotalWorkingTime), formatDuration(report.ExpectedWorkingTime), formatDuration(report.Overtime), ""},
+	}
+	for _, month := range report.Months {
+		content.Rows = append(content.Rows, htmlRow{Values: []string{
+			fmt.Sprintf("%04d-%02d", report.Year, month.Month),
+			strconv.Itoa(month.Workdays),
+			formatDuration(month.WorkingTime),
+			formatDuration(month.ExpectedWorkingTime),
+			formatDuration(month.Overtime),
+			formatDuration(month.OvertimeBalance),
+		}})
+	}
+	return renderHtmlReport(content)
+}
+
+// WriteDays renders a HTML document with a row for each of passed days and total working time.
+func (formatter *htmlReportFormatter) writeDays(period string, days []timetracker.Day, totalWorkingTime time.Duration, locale timetracker.Locale) (*bytes.Buffer, error) {
+
+	dateFormat := formatter.dateFormat
+	if locale.DateFormat != nil {
+		dateFormat = *locale.DateFormat
+	}
+	timezone, err := loadTimezone(locale)
+	if err != nil {
+		formatter.logger.Error("Unable to load location, reason: ", err)
+	}
+	formatTime := func(t time.Time) string {
+		if timezone != nil {
+			t = t.In(timezone)
+		}
+		return t.Format(formatter.timeFormat)
+	}
+
+	content := htmlReport{
+		Title:     "Time Tracking Report",
+		Period:    period,
+		Headlines: []string{"Date", "Start", "End", "Break", "Working Time", "Comment"},
+		Rows:      []htmlRow{},
+		Totals:    []string{"Total", "", "", "", formatDuration(totalWorkingTime), ""},
+	}
+	for _, day := range days {
+		start, end := "", ""
+		if len(day.Events) > 0 {
+			start = formatTime(day.Events[0].Timestamp)
+		}
+		if len(day.Events) > 1 {
+			end = formatTime(day.Events[len(day.Events)-1].Timestamp)
+		}
+		comment := ""
+		switch day.Type {
+		case timetracker.VACATION:
+			comment = "Vacation"
+		case timetracker.ILLNESS:
+			comment = "Illness"
+		}
+		holiday, isHoliday := formatter.holidays[day.Date]
+		if isHoliday {
+			comment = holiday.Description
+		}
+		weekday := day.Date.AsTime().Weekday()
+		content.Rows = append(content.Rows, htmlRow{
+			Values:    []string{day.Date.Format(dateFormat), start, end, formatDuration(day.BreakTime), formatDuration(day.WorkingTime), comment},
+			Highlight: isHoliday || weekday == time.Saturday || weekday == time.Sunday,
+		})
+	}
+	return renderHtmlReport(content)
+}
+
+// RenderHtmlReport executes html report template with given content.
+func renderHtmlReport(content htmlReport) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	if err := htmlReportTemplate.Execute(buf, content); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
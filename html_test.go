@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type HtmlReportFormatterTestSuite struct {
+	suite.Suite
+}
+
+func TestHtmlReportFormatterTestSuite(t *testing.T) {
+	suite.Run(t, new(HtmlReportFormatterTestSuite))
+}
+
+func (suite *HtmlReportFormatterTestSuite) TestWriteMonthlyReport() {
+
+	formatter := newHtmlReportFormatter(loggerForTest())
+	formatter.WithHolidays([]timetracker.Holiday{
+		{Date: timetracker.Date{Year: 2022, Month: 1, Day: 6}, Description: "Epiphany <Test>"},
+	})
+	report := &timetracker.MonthlyReport{
+		Year:     2022,
+		Month:    1,
+		Location: timetracker.Locale{Country: "DE", DateFormat: asStringPtr("02.01.2006")},
+		Days: []timetracker.Day{
+			{
+				Date:        timetracker.Date{Year: 2022, Month: 1, Day: 3},
+				Type:        timetracker.WORKDAY,
+				WorkingTime: 8 * time.Hour,
+				BreakTime:   30 * time.Minute,
+				Events: []timetracker.TimeTrackingRecord{
+					{Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 7, 0, 0, 0, time.UTC)},
+					{Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 15, 30, 0, 0, time.UTC)},
+				},
+			},
+		},
+		TotalWorkingTime: 8 * time.Hour,
+	}
+
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	suite.Nil(err)
+	html := buf.String()
+	suite.True(strings.HasPrefix(html, "<!DOCTYPE html>"))
+	suite.Equal(33, strings.Count(html, "<tr"))
+	suite.Contains(html, "<td style=\"padding: 4px;\">03.01.2022</td><td style=\"padding: 4px;\">07:00</td><td style=\"padding: 4px;\">15:30</td>")
+	suite.Contains(html, "Epiphany &lt;Test&gt;")
+	suite.Equal(".html", formatter.FileExtension())
+}
+
+func (suite *HtmlReportFormatterTestSuite) TestWritePeriodAndYearlyReport() {
+
+	formatter := newHtmlReportFormatter(loggerForTest())
+
+	buf1, err1 := formatter.WritePeriodReportToBuffer(periodReportForTest())
+	suite.Nil(err1)
+	suite.Contains(buf1.String(), "2021-12-27 - 2022-01-02")
+
+	buf2, err2 := formatter.WriteYearlyReportToBuffer(&yearlyReport{
+		Year:   2022,
+		Months: []monthlySummary{{Month: 1, Workdays: 21, WorkingTime: 170 * time.Hour, ExpectedWorkingTime: 168 * time.Hour, Overtime: 2 * time.Hour, OvertimeBalance: 2 * time.Hour}},
+	})
+	suite.Nil(err2)
+	suite.Contains(buf2.String(), "<td style=\"padding: 4px;\">2022-01</td>")
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	core "github.com/tommzn/hob-core"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Supported stores for completion state of report requests.
+const (
+	idempotencyStoreNone   = "none"
+	idempotencyStoreMemory = "memory"
+	idempotencyStoreFile   = "file"
+	idempotencyStoreS3     = "s3"
+)
+
+// IdempotencyStore keeps completion state of processed report requests.
+type idempotencyStore interface {
+
+	// CompletedAt returns the point in time a request with given key has been completed, or nil if it's not completed, yet.
+	completedAt(key string) (*time.Time, error)
+
+	// Complete marks a request with given key as completed.
+	complete(key string, completedAt time.Time) error
+}
+
+// IsCompleted returns true if a request with given key has been completed within configured TTL.
+// Requests are never completed if there's no idempotency store.
+func (handler *ReportGenerator) isCompleted(key string) bool {
+
+	if handler.idempotencyStore == nil {
+		return false
+	}
+	completedAt, err := handler.idempotencyStore.completedAt(key)
+	if err != nil {
+		handler.logger.Error("Unable to get completion state of request, reason: ", err)
+		return false
+	}
+	return completedAt != nil && time.Since(*completedAt) < handler.idempotencyTtl
+}
+
+// MarkCompleted keeps completion state of a request with given key, if there's an idempotency store.
+func (handler *ReportGenerator) markCompleted(key string) {
+
+	if handler.idempotencyStore == nil {
+		return
+	}
+	if err := handler.idempotencyStore.complete(key, time.Now().UTC()); err != nil {
+		handler.logger.Error("Unable to save completion state of request, reason: ", err)
+	}
+}
+
+// IdempotencyKey derives a key from type, period, devices, formats and delivery targets of given request.
+// If a request has an explicit request id, this id is used instead.
+func (handler *ReportGenerator) idempotencyKey(request *reportRequest) (string, error) {
+
+	if request.RequestId != "" {
+		return checksumOf([]byte("request:" + request.RequestId)), nil
+	}
+
+	start, end, err := handler.reportPeriod(request)
+	if err != nil {
+		return "", err
+	}
+	formats, err := request.reportFormats()
+	if err != nil {
+		return "", err
+	}
+	targets, err := request.deliveryTargets()
+	if err != nil {
+		return "", err
+	}
+	devices := append([]string{}, request.DeviceIds...)
+	if len(devices) == 0 {
+		devices = append(devices, handler.deviceIds...)
+	}
+	sort.Strings(devices)
+
+	delivery, err := deliveryJson(request)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := json.Marshal(struct {
+		Type        core.ReportType
+		Start, End  time.Time
+		Devices     []string
+		Formats     []core.ReportFormat
+		NamePattern string
+		Delivery    json.RawMessage
+		Targets     []string
+		Archive     bool
+		Mail        mailOptions
+		Chat        chatOptions
+	}{
+		Type:        request.Type,
+		Start:       start,
+		End:         end,
+		Devices:     devices,
+		Formats:     formats,
+		NamePattern: request.NamePattern,
+		Delivery:    delivery,
+		Targets:     targets,
+		Archive:     request.Archive,
+		Mail:        request.Mail,
+		Chat:        request.Chat,
+	})
+	if err != nil {
+		return "", err
+	}
+	return checksumOf(content), nil
+}
+
+// ReportPeriod returns start and end of the period a report will be generated for.
+func (handler *ReportGenerator) reportPeriod(request *reportRequest) (time.Time, time.Time, error) {
+
+	switch request.Type {
+
+	case core.ReportType_MONTHLY_REPORT:
+		start, end := reportTimeRange(request.GenerateReportRequest, handler.timezone())
+		return start, end, nil
+
+	case reportTypeWeekly:
+		return weeklyReportTimeRange(request, handler.timezone())
+
+	case reportTypeYearly:
+		start, end := yearlyReportTimeRange(request.GenerateReportRequest, handler.timezone())
+		return start, end, nil
+
+	case reportTypeDateRange:
+		return dateRangeReportTimeRange(request, handler.maxReportDays(), handler.timezone())
+
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("Unsupported report type: %s", request.Type)
+	}
+}
+
+// DeliveryJson returns delivery targets of given request as normalized JSON.
+func deliveryJson(request *reportRequest) (json.RawMessage, error) {
+	delivery := &core.ReportDelivery{}
+	if request.Delivery != nil {
+		delivery = request.Delivery
+	}
+	content, err := protojson.Marshal(delivery)
+	if err != nil {
+		return nil, err
+	}
+	return normalizedJson(content), nil
+}
+
+// NormalizedJson re-encodes passed JSON, protojson adds random whitespaces to prevent byte-by-byte comparison.
+func normalizedJson(content []byte) json.RawMessage {
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, content); err != nil {
+		return content
+	}
+	return buf.Bytes()
+}
+
+// ChecksumOf returns a hex encoded SHA-256 checksum of given content.
+func checksumOf(content []byte) string {
+	checksum := sha256.Sum256(content)
+	return hex.EncodeToString(checksum[:])
+}
+
+// NewMemoryIdempotencyStore returns a store which keeps completion state in memory.
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{completed: make(map[string]time.Time)}
+}
+
+// MemoryIdempotencyStore keeps completion state in memory, it's lost on restart.
+type memoryIdempotencyStore struct {
+	sync.RWMutex
+	completed map[string]time.Time
+}
+
+// CompletedAt returns completion time of given key.
+func (store *memoryIdempotencyStore) completedAt(key string) (*time.Time, error) {
+	store.RLock()
+	defer store.RUnlock()
+	if completedAt, ok := store.completed[key]; ok {
+		return &completedAt, nil
+	}
+	return nil, nil
+}
+
+// Complete keeps completion time of given key.
+func (store *memoryIdempotencyStore) complete(key string, completedAt time.Time) error {
+	store.Lock()
+	defer store.Unlock()
+	store.completed[key] = completedAt
+	return nil
+}
+
+// FileIdempotencyStore keeps completion state of each request in a file in a local directory.
+type fileIdempotencyStore struct {
+	path string
+}
+
+// CompletedAt reads completion time of given key from a local file.
+func (store *fileIdempotencyStore) completedAt(key string) (*time.Time, error) {
+	content, err := os.ReadFile(filepath.Join(store.path, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseCompletionTime(content)
+}
+
+// Complete writes completion time of given key to a local file.
+func (store *fileIdempotencyStore) complete(key string, completedAt time.Time) error {
+	if err := os.MkdirAll(store.path, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(store.path, key), []byte(completedAt.Format(time.RFC3339)), 0644)
+}
+
+// S3IdempotencyStore keeps completion state of each request in an object in a S3 bucket.
+type s3IdempotencyStore struct {
+	client   s3iface.S3API
+	bucket   *string
+	basePath string
+}
+
+// CompletedAt reads completion time of given key from a S3 object.
+func (store *s3IdempotencyStore) completedAt(key string) (*time.Time, error) {
+
+	output, err := store.client.GetObject(&s3.GetObjectInput{
+		Bucket: store.bucket,
+		Key:    store.objectKey(key),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseCompletionTime(content)
+}
+
+// Complete writes completion time of given key to a S3 object.
+func (store *s3IdempotencyStore) complete(key string, completedAt time.Time) error {
+	_, err := store.client.PutObject(&s3.PutObjectInput{
+		Bucket: store.bucket,
+		Key:    store.objectKey(key),
+		Body:   bytes.NewReader([]byte(completedAt.Format(time.RFC3339))),
+	})
+	return err
+}
+
+// ObjectKey returns the key of a S3 object for given request key.
+func (store *s3IdempotencyStore) objectKey(key string) *string {
+	objectKey := key
+	if store.basePath != "" {
+		objectKey = strings.TrimSuffix(store.basePath, "/") + "/" + key
+	}
+	return &objectKey
+}
+
+// ParseCompletionTime parses a completion time in RFC 3339 format.
+func parseCompletionTime(content []byte) (*time.Time, error) {
+	completedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	return &completedAt, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/suite"
+	config "github.com/tommzn/go-config"
+)
+
+type IdempotencyTestSuite struct {
+	suite.Suite
+}
+
+func TestIdempotencyTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotencyTestSuite))
+}
+
+func (suite *IdempotencyTestSuite) TestIdempotencyKey() {
+
+	handler := reportGeneratorForTest()
+	request1 := &reportRequest{GenerateReportRequest: eventForTest("")}
+	key1, err := handler.idempotencyKey(request1)
+	suite.Nil(err)
+	suite.Len(key1, 64)
+
+	request2 := &reportRequest{GenerateReportRequest: eventForTest("")}
+	request2.DeviceIds = append([]string{}, handler.deviceIds...)
+	key2, err := handler.idempotencyKey(request2)
+	suite.Nil(err)
+	suite.Equal(key1, key2)
+
+	request3 := &reportRequest{GenerateReportRequest: eventForTest("")}
+	request3.Delivery.File.Path = "/tmp/"
+	key3, _ := handler.idempotencyKey(request3)
+	suite.NotEqual(key1, key3)
+
+	request4 := &reportRequest{GenerateReportRequest: eventForTest("")}
+	request4.Month = 2
+	key4, _ := handler.idempotencyKey(request4)
+	suite.NotEqual(key1, key4)
+
+	request5 := &reportRequest{GenerateReportRequest: eventForTest(""), reportOptions: reportOptions{Formats: []string{"csv"}}}
+	key5, _ := handler.idempotencyKey(request5)
+	suite.NotEqual(key1, key5)
+
+	request6 := &reportRequest{GenerateReportRequest: eventForTest(""), reportOptions: reportOptions{RequestId: "request-1"}}
+	request7 := &reportRequest{GenerateReportRequest: eventForTest(""), reportOptions: reportOptions{RequestId: "request-1"}}
+	request7.Month = 2
+	key6, _ := handler.idempotencyKey(request6)
+	key7, _ := handler.idempotencyKey(request7)
+	suite.NotEqual(key1, key6)
+	suite.Equal(key6, key7)
+
+	request8 := &reportRequest{GenerateReportRequest: eventForTest(""), reportOptions: reportOptions{Targets: []string{"webhook"}}}
+	key8, _ := handler.idempotencyKey(request8)
+	suite.NotEqual(key1, key8)
+
+	_, err = handler.idempotencyKey(&reportRequest{GenerateReportRequest: eventWithInvalidTypeForTest("")})
+	suite.NotNil(err)
+}
+
+func (suite *IdempotencyTestSuite) TestFileIdempotencyStore() {
+
+	store := &fileIdempotencyStore{path: suite.T().TempDir() + "/idempotency"}
+	completedAt, err := store.completedAt("key")
+	suite.Nil(err)
+	suite.Nil(completedAt)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	suite.Nil(store.complete("key", now))
+	completedAt, err = store.completedAt("key")
+	suite.Nil(err)
+	suite.Equal(now, *completedAt)
+
+	suite.Nil(os.WriteFile(store.path+"/invalid", []byte("xxx"), 0644))
+	_, err = store.completedAt("invalid")
+	suite.NotNil(err)
+}
+
+func (suite *IdempotencyTestSuite) TestS3IdempotencyStore() {
+
+	client := &s3ClientMock{objects: make(map[string]string)}
+	store := &s3IdempotencyStore{client: client, bucket: aws.String("reports"), basePath: "idempotency/"}
+	completedAt, err := store.completedAt("key")
+	suite.Nil(err)
+	suite.Nil(completedAt)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	suite.Nil(store.complete("key", now))
+	suite.Contains(client.objects, "idempotency/key")
+	completedAt, err = store.completedAt("key")
+	suite.Nil(err)
+	suite.Equal(now, *completedAt)
+
+	client.err = errors.New("Access denied")
+	_, err = store.completedAt("key")
+	suite.NotNil(err)
+}
+
+func (suite *IdempotencyTestSuite) TestIdempotencyStoreFromConfig() {
+
+	awsConf := awsConfig{region: aws.String("eu-central-1"), bucket: aws.String("reports")}
+	store1, ttl1, err1 := idempotencyStoreFromConfig(emptyConfigForTest(), awsConf)
+	suite.Nil(err1)
+	suite.Nil(store1)
+	suite.Equal(24*time.Hour, ttl1)
+
+	store2, ttl2, err2 := idempotencyStoreFromConfig(suite.configForTest("store: s3\n    ttl: 1h"), awsConf)
+	suite.Nil(err2)
+	suite.IsType(&s3IdempotencyStore{}, store2)
+	suite.Equal("reports", *store2.(*s3IdempotencyStore).bucket)
+	suite.Equal(time.Hour, ttl2)
+
+	store3, _, err3 := idempotencyStoreFromConfig(suite.configForTest("store: file\n    path: /tmp/idempotency"), awsConf)
+	suite.Nil(err3)
+	suite.Equal(&fileIdempotencyStore{path: "/tmp/idempotency"}, store3)
+
+	_, _, err4 := idempotencyStoreFromConfig(suite.configForTest("store: file"), awsConf)
+	suite.NotNil(err4)
+
+	store5, _, err5 := idempotencyStoreFromConfig(suite.configForTest("store: memory"), awsConf)
+	suite.Nil(err5)
+	suite.IsType(&memoryIdempotencyStore{}, store5)
+
+	_, _, err6 := idempotencyStoreFromConfig(suite.configForTest("store: redis"), awsConf)
+	suite.NotNil(err6)
+}
+
+func (suite *IdempotencyTestSuite) configForTest(idempotencyConfig string) config.Config {
+	conf, err := config.NewStaticConfigSource("hob:\n  idempotency:\n    " + idempotencyConfig + "\n").Load()
+	suite.Nil(err)
+	return conf
+}
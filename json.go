@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/tommzn/go-log"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+// JsonReportSchemaVersion is the version of schema/report-v1.schema.json all JSON reports are generated with.
+// Increase it for each change of the schema, major version for changes which are not backward compatible.
+const jsonReportSchemaVersion = "1.0"
+
+// NewJsonReportFormatter returns a new formatter to generate machine-readable JSON reports.
+func newJsonReportFormatter(logger log.Logger) *jsonReportFormatter {
+	return &jsonReportFormatter{
+		holidays: []timetracker.Holiday{},
+		logger:   logger,
+	}
+}
+
+// JsonReportFormatter generates JSON documents for reports. All documents follow schema/report-v1.schema.json.
+type jsonReportFormatter struct {
+
+	// Holidays is a list of public holidays.
+	holidays []timetracker.Holiday
+
+	logger log.Logger
+}
+
+// JsonReport is the root object of a JSON report.
+type jsonReport struct {
+	SchemaVersion string        `json:"schemaVersion"`
+	ReportType    string        `json:"reportType"`
+	GeneratedAt   time.Time     `json:"generatedAt"`
+	TimeRange     jsonTimeRange `json:"timeRange"`
+	Devices       []string      `json:"devices"`
+	Locale        jsonLocale    `json:"locale"`
+	Days          []jsonDay     `json:"days"`
+	Months        []jsonMonth   `json:"months"`
+	Totals        jsonTotals    `json:"totals"`
+	Holidays      []jsonHoliday `json:"holidays"`
+}
+
+// JsonTimeRange is the first and the last day of a report.
+type jsonTimeRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// JsonLocale contains country and timezone a report has been generated for.
+type jsonLocale struct {
+	Country  string  `json:"country"`
+	Timezone *string `json:"timezone"`
+}
+
+// JsonDay contains type, working and break time of a single day.
+type jsonDay struct {
+	Date               string     `json:"date"`
+	Type               string     `json:"type"`
+	Start              *time.Time `json:"start"`
+	End                *time.Time `json:"end"`
+	WorkingTimeMinutes int64      `json:"workingTimeMinutes"`
+	BreakTimeMinutes   int64      `json:"breakTimeMinutes"`
+	Holiday            *string    `json:"holiday"`
+}
+
+// JsonMonth is a summary of a single month, used by yearly reports.
+type jsonMonth struct {
+	Month                      int   `json:"month"`
+	Workdays                   int   `json:"workdays"`
+	WorkingTimeMinutes         int64 `json:"workingTimeMinutes"`
+	ExpectedWorkingTimeMinutes int64 `json:"expectedWorkingTimeMinutes"`
+	OvertimeMinutes            int64 `json:"overtimeMinutes"`
+	OvertimeBalanceMinutes     int64 `json:"overtimeBalanceMinutes"`
+}
+
+// JsonTotals contains total working time of a report. Expected working time and overtime are available for yearly reports, only.
+type jsonTotals struct {
+	WorkingTimeMinutes         int64  `json:"workingTimeMinutes"`
+	ExpectedWorkingTimeMinutes *int64 `json:"expectedWorkingTimeMinutes"`
+	OvertimeMinutes            *int64 `json:"overtimeMinutes"`
+}
+
+// JsonHoliday is a single public holiday.
+type jsonHoliday struct {
+	Date        string `json:"date"`
+	Description string `json:"description"`
+}
+
+// FileExtension returns file extension for JSON files: json.
+func (formatter *jsonReportFormatter) FileExtension() string {
+	return ".json"
+}
+
+// WithHolidays will assign give list of holidays for output formatting.
+func (formatter *jsonReportFormatter) WithHolidays(holidays []timetracker.Holiday) {
+	formatter.holidays = holidays
+}
+
+// WriteMonthlyReportToFile will generate a report output an writes it to given file.
+func (formatter *jsonReportFormatter) WriteMonthlyReportToFile(report *timetracker.MonthlyReport, filename string) error {
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// WriteMonthlyReportToBuffer returns a JSON document with all days of given monthly report.
+func (formatter *jsonReportFormatter) WriteMonthlyReportToBuffer(report *timetracker.MonthlyReport) (*bytes.Buffer, error) {
+	firstOfMonth := time.Date(report.Year, time.Month(report.Month), 1, 0, 0, 0, 0, time.UTC)
+	days := daysInMonth(report)
+	content := formatter.newJsonReport("monthly", firstOfMonth, firstOfMonth.AddDate(0, 1, -1), days, report.Location)
+	content.Totals.WorkingTimeMinutes = minutes(report.TotalWorkingTime)
+	return writeJson(content)
+}
+
+// WritePeriodReportToBuffer returns a JSON document with all days of given report.
+func (formatter *jsonReportFormatter) WritePeriodReportToBuffer(report *periodReport) (*bytes.Buffer, error) {
+	content := formatter.newJsonReport("period", report.Start, report.End, report.Days, report.Location)
+	content.Totals.WorkingTimeMinutes = minutes(report.TotalWorkingTime)
+	return writeJson(content)
+}
+
+// WriteYearlyReportToBuffer returns a JSON document with a summary of each month of given yearly report.
+func (formatter *jsonReportFormatter) WriteYearlyReportToBuffer(report *yearlyReport) (*bytes.Buffer, error) {
+
+	firstOfYear := time.Date(report.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+	content := formatter.newJsonReport("yearly", firstOfYear, firstOfYear.AddDate(1, 0, -1), []timetracker.Day{}, report.Location)
+	for _, month := range report.Months {
+		content.Months = append(content.Months, jsonMonth{
+			Month:                      month.Month,
+			Workdays:                   month.Workdays,
+			WorkingTimeMinutes:         minutes(month.WorkingTime),
+			ExpectedWorkingTimeMinutes: minutes(month.ExpectedWorkingTime),
+			OvertimeMinutes:            minutes(month.Overtime),
+			OvertimeBalanceMinutes:     minutes(month.OvertimeBalance),
+		})
+	}
+	expectedWorkingTime := minutes(report.ExpectedWorkingTime)
+	overtime := minutes(report.Overtime)
+	content.Totals = jsonTotals{
+		WorkingTimeMinutes:         minutes(report.TotalWorkingTime),
+		ExpectedWorkingTimeMinutes: &expectedWorkingTime,
+		OvertimeMinutes:            &overtime,
+	}
+	return writeJson(content)
+}
+
+// NewJsonReport creates a JSON report for given time range with passed days and all holidays within this range.
+func (formatter *jsonReportFormatter) newJsonReport(reportType string, start, end time.Time, days []timetracker.Day, locale timetracker.Locale) *jsonReport {
+
+	holidays := make(map[timetracker.Date]string)
+	content := &jsonReport{
+		SchemaVersion: jsonReportSchemaVersion,
+		ReportType:    reportType,
+		GeneratedAt:   time.Now().UTC().Truncate(time.Second),
+		TimeRange:     jsonTimeRange{Start: start.Format(dateRangeLayout), End: end.Format(dateRangeLayout)},
+		Devices:       deviceIdsOf(days),
+		Locale:        jsonLocale{Country: strings.ToUpper(locale.Country), Timezone: locale.Timezone},
+		Days:          []jsonDay{},
+		Months:        []jsonMonth{},
+		Holidays:      []jsonHoliday{},
+	}
+	// Holidays are compared with dates of start and end in their timezone.
+	firstDay, lastDay := asUtcDate(start), asUtcDate(end)
+	for _, holiday := range formatter.holidays {
+		if holidayTime := holiday.Date.AsTime(); !holidayTime.Before(firstDay) && !holidayTime.After(lastDay) {
+			content.Holidays = append(content.Holidays, jsonHoliday{Date: holiday.Date.String(), Description: holiday.Description})
+			holidays[holiday.Date] = holiday.Description
+		}
+	}
+
+	for _, day := range days {
+		jsonDay := jsonDay{
+			Date:               day.Date.String(),
+			Type:               string(day.Type),
+			WorkingTimeMinutes: minutes(day.WorkingTime),
+			BreakTimeMinutes:   minutes(day.BreakTime),
+		}
+		if jsonDay.Type == "" {
+			jsonDay.Type = string(timetracker.WORKDAY)
+		}
+		if len(day.Events) > 0 {
+			start := day.Events[0].Timestamp.UTC()
+			jsonDay.Start = &start
+		}
+		if len(day.Events) > 1 {
+			end := day.Events[len(day.Events)-1].Timestamp.UTC()
+			jsonDay.End = &end
+		}
+		if description, ok := holidays[day.Date]; ok {
+			jsonDay.Holiday = &description
+		}
+		content.Days = append(content.Days, jsonDay)
+	}
+	return content
+}
+
+// Minutes returns given duration as number of minutes, rounded to the nearest minute.
+func minutes(d time.Duration) int64 {
+	return int64(d.Round(time.Minute) / time.Minute)
+}
+
+// WriteJson encodes passed content as indented JSON.
+func writeJson(content interface{}) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(content); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
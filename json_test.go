@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type JsonReportFormatterTestSuite struct {
+	suite.Suite
+	schema *jsonschema.Schema
+}
+
+func TestJsonReportFormatterTestSuite(t *testing.T) {
+	suite.Run(t, new(JsonReportFormatterTestSuite))
+}
+
+func (suite *JsonReportFormatterTestSuite) SetupSuite() {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	schema, err := compiler.Compile("schema/report-v1.schema.json")
+	suite.Nil(err)
+	suite.schema = schema
+}
+
+func (suite *JsonReportFormatterTestSuite) TestWriteMonthlyReport() {
+
+	formatter := newJsonReportFormatter(loggerForTest())
+	formatter.WithHolidays([]timetracker.Holiday{
+		{Date: timetracker.Date{Year: 2022, Month: 1, Day: 6}, Description: "Epiphany"},
+		{Date: timetracker.Date{Year: 2022, Month: 4, Day: 18}, Description: "Easter Monday"},
+	})
+	report := &timetracker.MonthlyReport{
+		Year:     2022,
+		Month:    1,
+		Location: timetracker.Locale{Country: "de", Timezone: asStringPtr("Europe/Berlin")},
+		Days: []timetracker.Day{
+			{
+				Date:        timetracker.Date{Year: 2022, Month: 1, Day: 3},
+				Type:        timetracker.WORKDAY,
+				WorkingTime: 8 * time.Hour,
+				BreakTime:   30 * time.Minute,
+				Events: []timetracker.TimeTrackingRecord{
+					{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 7, 0, 0, 0, time.UTC)},
+					{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 15, 30, 0, 0, time.UTC)},
+				},
+			},
+		},
+		TotalWorkingTime: 8 * time.Hour,
+	}
+
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	suite.Nil(err)
+	suite.assertValidReport(buf)
+
+	content := jsonReport{}
+	suite.Nil(json.Unmarshal(buf.Bytes(), &content))
+	suite.Equal(jsonReportSchemaVersion, content.SchemaVersion)
+	suite.Equal("monthly", content.ReportType)
+	suite.Equal(jsonTimeRange{Start: "2022-01-01", End: "2022-01-31"}, content.TimeRange)
+	suite.Equal([]string{"Device01"}, content.Devices)
+	suite.Equal("DE", content.Locale.Country)
+	suite.Len(content.Days, 31)
+	suite.Equal(int64(480), content.Days[2].WorkingTimeMinutes)
+	suite.Equal(int64(30), content.Days[2].BreakTimeMinutes)
+	suite.NotNil(content.Days[2].Start)
+	suite.NotNil(content.Days[5].Holiday)
+	suite.Len(content.Holidays, 1)
+	suite.Equal(int64(480), content.Totals.WorkingTimeMinutes)
+	suite.Nil(content.Totals.OvertimeMinutes)
+	suite.Equal(".json", formatter.FileExtension())
+}
+
+func (suite *JsonReportFormatterTestSuite) TestWritePeriodReport() {
+
+	formatter := newJsonReportFormatter(loggerForTest())
+
+	buf, err := formatter.WritePeriodReportToBuffer(periodReportForTest())
+	suite.Nil(err)
+	suite.assertValidReport(buf)
+}
+
+func (suite *JsonReportFormatterTestSuite) TestHolidaysOfPeriodReportInTimezone() {
+
+	newYork, err := time.LoadLocation("America/New_York")
+	suite.Nil(err)
+	formatter := newJsonReportFormatter(loggerForTest())
+	formatter.WithHolidays([]timetracker.Holiday{
+		{Date: timetracker.Date{Year: 2022, Month: 12, Day: 25}, Description: "Christmas Day"},
+		{Date: timetracker.Date{Year: 2022, Month: 12, Day: 26}, Description: "Christmas Day observed"},
+		{Date: timetracker.Date{Year: 2023, Month: 1, Day: 2}, Description: "New Year's Day observed"},
+	})
+	start := time.Date(2022, 12, 26, 0, 0, 0, 0, newYork)
+	report := &periodReport{
+		Start:    start,
+		End:      start.AddDate(0, 0, 7).Add(-1 * time.Second),
+		Location: timetracker.Locale{Country: "us", Timezone: asStringPtr("America/New_York")},
+		Days:     []timetracker.Day{{Date: timetracker.Date{Year: 2022, Month: 12, Day: 26}, Type: timetracker.WORKDAY}},
+	}
+
+	buf, err := formatter.WritePeriodReportToBuffer(report)
+	suite.Nil(err)
+	suite.assertValidReport(buf)
+
+	content := jsonReport{}
+	suite.Nil(json.Unmarshal(buf.Bytes(), &content))
+	suite.Equal(jsonTimeRange{Start: "2022-12-26", End: "2023-01-01"}, content.TimeRange)
+	suite.Equal([]jsonHoliday{{Date: "2022-12-26", Description: "Christmas Day observed"}}, content.Holidays)
+	suite.NotNil(content.Days[0].Holiday)
+}
+
+func (suite *JsonReportFormatterTestSuite) TestWriteYearlyReport() {
+
+	formatter := newJsonReportFormatter(loggerForTest())
+	report := &yearlyReport{
+		Year:     2022,
+		Location: timetracker.Locale{Country: "NL"},
+		Months: []monthlySummary{
+			{Month: 1, Workdays: 21, WorkingTime: 170 * time.Hour, ExpectedWorkingTime: 168 * time.Hour, Overtime: 2 * time.Hour, OvertimeBalance: 2 * time.Hour},
+		},
+		TotalWorkingTime:    170 * time.Hour,
+		ExpectedWorkingTime: 168 * time.Hour,
+		Overtime:            2 * time.Hour,
+	}
+
+	buf, err := formatter.WriteYearlyReportToBuffer(report)
+	suite.Nil(err)
+	suite.assertValidReport(buf)
+
+	content := jsonReport{}
+	suite.Nil(json.Unmarshal(buf.Bytes(), &content))
+	suite.Len(content.Months, 1)
+	suite.Equal(int64(120), *content.Totals.OvertimeMinutes)
+}
+
+func (suite *JsonReportFormatterTestSuite) TestSchemaRejectsInvalidReports() {
+
+	var report interface{}
+	suite.Nil(json.Unmarshal([]byte(`{"schemaVersion": "2.0", "reportType": "monthly"}`), &report))
+	suite.NotNil(suite.schema.Validate(report))
+}
+
+func (suite *JsonReportFormatterTestSuite) assertValidReport(buf *bytes.Buffer) {
+	var report interface{}
+	suite.Nil(json.Unmarshal(buf.Bytes(), &report))
+	suite.Nil(suite.schema.Validate(report))
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	log "github.com/tommzn/go-log"
+)
+
+// TemplateFuncs are available in email and chat templates, e.g. {{ duration .Overtime }}.
+var templateFuncs = map[string]interface{}{
+	"duration": formatDuration,
+	"join":     strings.Join,
+}
+
+// DefaultMailSubject and defaultMailBody are used if there's no template for a report locale.
+const (
+	defaultMailSubject = `Time Tracking Report {{ .Start.Format "200601" }}`
+	defaultMailBody    = `<p>PFA your monthly time tracking report!</p></br>`
+)
+
+var defaultMailTemplate = mustMailTemplate(newMailTemplate(defaultMailSubject, defaultMailBody))
+
+// NewMailPublisher returns a publisher which sends reports with given transport, AWS SES or SMTP, to all passed
+// recipients. Subject and message are rendered with a template for the report locale. Depending on passed options
+// a report will be embedded as HTML into the email body and attached as file.
+func newMailPublisher(source string, recipients mailRecipients, templates mailTemplates, transport mailTransport, options mailOptions, logger log.Logger) *mailPublisher {
+	return &mailPublisher{
+		source:     source,
+		recipients: recipients,
+		templates:  templates,
+		transport:  transport,
+		options:    options,
+		logger:     logger,
+	}
+}
+
+// MailTransport delivers a MIME message to all passed destinations.
+type mailTransport interface {
+	send(source string, destinations []string, rawEMail []byte) error
+}
+
+// SesTransport sends emails via AWS SES.
+type sesTransport struct {
+
+	// Client is used to send emails. If not set a new client will be created on first send.
+	client sesiface.SESAPI
+}
+
+// MailTemplate contains a text template for an email subject and a HTML template for an email body.
+type mailTemplate struct {
+	subject *texttemplate.Template
+	body    *htmltemplate.Template
+}
+
+// MailTemplates are email templates indexed by country, ISO 3166-1 codes in upper case.
+// Templates with an empty country are used as default.
+type mailTemplates map[string]*mailTemplate
+
+// MailRecipients contains all receivers of an email, together with an optional reply address.
+type mailRecipients struct {
+	to, cc, bcc []string
+	replyTo     string
+}
+
+// MailPublisher delivers reports via email.
+type mailPublisher struct {
+	source string
+
+	// Subject and message are rendered from templates as soon as a report is available.
+	subject, message string
+
+	// Templates are used to render email subject and message, depending on locale of a report.
+	templates mailTemplates
+
+	// Recipients are all To, CC and BCC receivers of an email.
+	recipients mailRecipients
+
+	// Options define if a report should be embedded into the email body and if it should be attached.
+	options mailOptions
+
+	// Body is a HTML report which is used as email body if inline reports are enabled.
+	body []byte
+
+	// Attachments are all files of current report, they're sent with a single email.
+	attachments []reportFile
+
+	// Transport is used to send emails.
+	transport mailTransport
+
+	logger log.Logger
+}
+
+// WithReport renders subject and message for passed report and renders the report itself as HTML
+// if it should be embedded into email body.
+func (publisher *mailPublisher) WithReport(report *generatedReport) error {
+
+	summary := newReportSummary(report)
+	subject, message, err := publisher.templates.forCountry(summary.Locale.Country).render(summary)
+	if err != nil {
+		return err
+	}
+	publisher.subject = subject
+	publisher.message = message
+	publisher.attachments = []reportFile{}
+
+	if !publisher.options.InlineReport {
+		return nil
+	}
+	formatter := newHtmlReportFormatter(publisher.logger)
+	formatter.WithHolidays(report.Holidays)
+	body, err := formatReport(formatter, report.Report)
+	if err != nil {
+		return err
+	}
+	publisher.body = body.Bytes()
+	return nil
+}
+
+// Send keeps given report file, it's attached to an email which is sent after all files have been published.
+func (publisher *mailPublisher) Send(content []byte, fileName string) error {
+	publisher.attachments = append(publisher.attachments, reportFile{name: fileName, content: content})
+	return nil
+}
+
+// Complete sends a single email with all report files passed to Send as attachments.
+func (publisher *mailPublisher) Complete() error {
+
+	rawEMail, err := publisher.rawEMail(publisher.attachments)
+	if err != nil {
+		return err
+	}
+	publisher.attachments = []reportFile{}
+
+	err = publisher.transport.send(publisher.source, publisher.recipients.all(), rawEMail)
+	if err != nil {
+		publisher.logger.Error("Unable to send report via email, reason: ", err)
+	}
+	return err
+}
+
+// Send passes given MIME message to AWS SES.
+func (transport *sesTransport) send(source string, destinations []string, rawEMail []byte) error {
+	if transport.client == nil {
+		transport.client = ses.New(session.Must(session.NewSession()))
+	}
+	_, err := transport.client.SendRawEmail(&ses.SendRawEmailInput{
+		Destinations: aws.StringSlice(destinations),
+		Source:       aws.String(source),
+		RawMessage:   &ses.RawMessage{Data: rawEMail},
+	})
+	return err
+}
+
+// RawEMail generates a MIME message with a HTML body and, if not disabled by options, all given files as attachments.
+func (publisher *mailPublisher) rawEMail(attachments []reportFile) ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("From: " + publisher.source + "\r\n")
+	buf.WriteString("To: " + strings.Join(publisher.recipients.to, ", ") + "\r\n")
+	if len(publisher.recipients.cc) > 0 {
+		buf.WriteString("Cc: " + strings.Join(publisher.recipients.cc, ", ") + "\r\n")
+	}
+	if publisher.recipients.replyTo != "" {
+		buf.WriteString("Reply-To: " + publisher.recipients.replyTo + "\r\n")
+	}
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", publisher.subject) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: multipart/mixed; boundary=\"" + writer.Boundary() + "\"\r\n\r\n")
+
+	body := []byte(publisher.message)
+	if publisher.options.InlineReport && len(publisher.body) > 0 {
+		body = publisher.body
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "base64")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(base64Lines(body)); err != nil {
+		return nil, err
+	}
+
+	if !publisher.options.WithoutAttachment {
+		for _, attachment := range attachments {
+			header = make(textproto.MIMEHeader)
+			header.Set("Content-Type", contentType(attachment.name)+"; name=\""+attachment.name+"\"")
+			header.Set("Content-Disposition", "attachment; filename=\""+attachment.name+"\"")
+			header.Set("Content-Transfer-Encoding", "base64")
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := part.Write(base64Lines(attachment.content)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewMailTemplate parses passed subject and body templates.
+func newMailTemplate(subject, body string) (*mailTemplate, error) {
+
+	subjectTemplate, err := texttemplate.New("subject").Funcs(templateFuncs).Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	bodyTemplate, err := htmltemplate.New("body").Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &mailTemplate{subject: subjectTemplate, body: bodyTemplate}, nil
+}
+
+// MustMailTemplate panics if a template can't be parsed.
+func mustMailTemplate(tmpl *mailTemplate, err error) *mailTemplate {
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+// Render executes subject and body template with given report summary.
+func (tmpl *mailTemplate) render(summary reportSummary) (string, string, error) {
+
+	subject := new(bytes.Buffer)
+	if err := tmpl.subject.Execute(subject, summary); err != nil {
+		return "", "", err
+	}
+	body := new(bytes.Buffer)
+	if err := tmpl.body.Execute(body, summary); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+// ForCountry returns the template for given country. If there's no template for this country,
+// the template without country or the default template will be used.
+func (templates mailTemplates) forCountry(country string) *mailTemplate {
+	if tmpl, ok := templates[strings.ToUpper(country)]; ok {
+		return tmpl
+	}
+	if tmpl, ok := templates[""]; ok {
+		return tmpl
+	}
+	return defaultMailTemplate
+}
+
+// All returns all To, CC and BCC addresses. BCC addresses are not part of email headers,
+// so they have to be passed as destinations.
+func (recipients mailRecipients) all() []string {
+	addresses := append([]string{}, recipients.to...)
+	addresses = append(addresses, recipients.cc...)
+	return append(addresses, recipients.bcc...)
+}
+
+// ValidAddresses returns all passed email addresses which can be parsed as RFC 5322 address.
+// Invalid addresses are logged and skipped.
+func validAddresses(addresses []string, logger log.Logger) []string {
+	valid := []string{}
+	for _, address := range addresses {
+		parsedAddress, err := mail.ParseAddress(strings.TrimSpace(address))
+		if err != nil {
+			logger.Errorf("Skip invalid email address %s, reason: %s", address, err)
+			continue
+		}
+		if parsedAddress.Name == "" {
+			valid = append(valid, parsedAddress.Address)
+		} else {
+			valid = append(valid, parsedAddress.String())
+		}
+	}
+	return valid
+}
+
+// ContentType returns a MIME type for given file name, based on its extension.
+func contentType(fileName string) string {
+	extension := filepath.Ext(fileName)
+	if extension == ".xlsx" {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	if mimeType := mime.TypeByExtension(extension); mimeType != "" {
+		return strings.Split(mimeType, ";")[0]
+	}
+	return "application/octet-stream"
+}
+
+// Base64Lines encodes passed content with base64 and splits it into lines of 76 characters, as required by RFC 2045.
+func base64Lines(content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	buf := new(bytes.Buffer)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	return buf.Bytes()
+}
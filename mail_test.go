@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type MailPublisherTestSuite struct {
+	suite.Suite
+}
+
+func TestMailPublisherTestSuite(t *testing.T) {
+	suite.Run(t, new(MailPublisherTestSuite))
+}
+
+func (suite *MailPublisherTestSuite) TestSendInlineReport() {
+
+	client := &sesClientMock{}
+	publisher := newMailPublisher("from@example.com", mailRecipients{to: []string{"to@example.com"}}, mailTemplatesForTest(), &sesTransport{}, mailOptions{InlineReport: true}, loggerForTest())
+	publisher.transport = &sesTransport{client: client}
+
+	suite.Nil(publisher.WithReport(generatedReportForTest()))
+	suite.Nil(publisher.Send([]byte("report"), "report.pdf"))
+	suite.Len(client.inputs, 0)
+	suite.Nil(publisher.Complete())
+	suite.Len(client.inputs, 1)
+	suite.Equal("from@example.com", *client.inputs[0].Source)
+	suite.Equal("to@example.com", *client.inputs[0].Destinations[0])
+
+	rawEMail := string(client.inputs[0].RawMessage.Data)
+	suite.Contains(rawEMail, "Subject: Report")
+	suite.Contains(rawEMail, "Content-Type: application/pdf; name=\"report.pdf\"")
+	suite.NotContains(rawEMail, string(base64Lines([]byte("<p>Message</p>"))))
+
+	client.err = errors.New("Test Error")
+	suite.Nil(publisher.Send([]byte("report"), "report.pdf"))
+	suite.NotNil(publisher.Complete())
+}
+
+func (suite *MailPublisherTestSuite) TestSendWithoutAttachment() {
+
+	client := &sesClientMock{}
+	publisher := newMailPublisher("from@example.com", mailRecipients{to: []string{"to@example.com"}}, mailTemplatesForTest(), &sesTransport{}, mailOptions{WithoutAttachment: true}, loggerForTest())
+	publisher.transport = &sesTransport{client: client}
+
+	suite.Nil(publisher.WithReport(generatedReportForTest()))
+	suite.Nil(publisher.Send([]byte("report"), "report.xlsx"))
+	suite.Nil(publisher.Send([]byte("report"), "report.pdf"))
+	suite.Nil(publisher.Complete())
+	suite.Len(client.inputs, 1)
+	rawEMail := string(client.inputs[0].RawMessage.Data)
+	suite.Contains(rawEMail, string(base64Lines([]byte("<p>Message</p>"))))
+	suite.False(strings.Contains(rawEMail, "attachment"))
+}
+
+func (suite *MailPublisherTestSuite) TestSendAllFilesWithSingleEmail() {
+
+	client := &sesClientMock{}
+	publisher := newMailPublisher("from@example.com", mailRecipients{to: []string{"to@example.com"}}, mailTemplatesForTest(), &sesTransport{client: client}, mailOptions{}, loggerForTest())
+
+	suite.Nil(publisher.WithReport(generatedReportForTest()))
+	suite.Nil(publisher.Send([]byte("excel"), "report.xlsx"))
+	suite.Nil(publisher.Send([]byte("pdf"), "report.pdf"))
+	suite.Nil(publisher.Send([]byte("csv"), "report.csv"))
+	suite.Nil(publisher.Complete())
+	suite.Len(client.inputs, 1)
+
+	rawEMail := string(client.inputs[0].RawMessage.Data)
+	suite.Contains(rawEMail, "attachment; filename=\"report.xlsx\"")
+	suite.Contains(rawEMail, "attachment; filename=\"report.pdf\"")
+	suite.Contains(rawEMail, "attachment; filename=\"report.csv\"")
+	suite.Contains(rawEMail, string(base64Lines([]byte("csv"))))
+
+	// Attachments of a report are sent only once.
+	suite.Nil(publisher.WithReport(generatedReportForTest()))
+	suite.Nil(publisher.Send([]byte("excel"), "report.xlsx"))
+	suite.Nil(publisher.Complete())
+	suite.Len(client.inputs, 2)
+	suite.NotContains(string(client.inputs[1].RawMessage.Data), "report.pdf")
+}
+
+func (suite *MailPublisherTestSuite) TestSendToAllRecipients() {
+
+	client := &sesClientMock{}
+	recipients := mailRecipients{
+		to:      []string{"employee@example.com", "manager@example.com"},
+		cc:      []string{"hr@example.com"},
+		bcc:     []string{"archive@example.com"},
+		replyTo: "office@example.com",
+	}
+	publisher := newMailPublisher("from@example.com", recipients, mailTemplatesForTest(), &sesTransport{}, mailOptions{}, loggerForTest())
+	publisher.transport = &sesTransport{client: client}
+
+	suite.Nil(publisher.WithReport(generatedReportForTest()))
+	suite.Nil(publisher.Send([]byte("report"), "report.xlsx"))
+	suite.Nil(publisher.Complete())
+	suite.Len(client.inputs, 1)
+	destinations := []string{}
+	for _, destination := range client.inputs[0].Destinations {
+		destinations = append(destinations, *destination)
+	}
+	suite.Equal([]string{"employee@example.com", "manager@example.com", "hr@example.com", "archive@example.com"}, destinations)
+
+	rawEMail := string(client.inputs[0].RawMessage.Data)
+	suite.Contains(rawEMail, "To: employee@example.com, manager@example.com\r\n")
+	suite.Contains(rawEMail, "Cc: hr@example.com\r\n")
+	suite.Contains(rawEMail, "Reply-To: office@example.com\r\n")
+	suite.NotContains(rawEMail, "archive@example.com")
+}
+
+func (suite *MailPublisherTestSuite) TestRenderLocalizedTemplate() {
+
+	germanTemplate, err := newMailTemplate(
+		"Arbeitszeitnachweis {{ .Period }}",
+		"<p>Geräte: {{ join .Devices \", \" }}, Arbeitszeit: {{ duration .TotalWorkingTime }}, Überstunden: {{ duration .Overtime }}</p>")
+	suite.Nil(err)
+	templates := mailTemplatesForTest()
+	templates["DE"] = germanTemplate
+
+	report := generatedReportForTest()
+	report.Report.(*timetracker.MonthlyReport).Location = timetracker.Locale{Country: "de", DefaultWorkTime: 8 * time.Hour}
+	report.Report.(*timetracker.MonthlyReport).TotalWorkingTime = 170 * time.Hour
+	publisher := newMailPublisher("from@example.com", mailRecipients{to: []string{"to@example.com"}}, templates, &sesTransport{}, mailOptions{}, loggerForTest())
+	suite.Nil(publisher.WithReport(report))
+	suite.Equal("Arbeitszeitnachweis 2022-01", publisher.subject)
+	suite.Equal("<p>Geräte: Device01, Arbeitszeit: 170:00, Überstunden: 02:00</p>", publisher.message)
+
+	report.Report.(*timetracker.MonthlyReport).Location.Country = "nl"
+	suite.Nil(publisher.WithReport(report))
+	suite.Equal("Report", publisher.subject)
+
+	publisher.templates = mailTemplates{}
+	suite.Nil(publisher.WithReport(report))
+	suite.Equal("Time Tracking Report 202201", publisher.subject)
+	suite.Equal("<p>PFA your monthly time tracking report!</p></br>", publisher.message)
+
+	_, err = newMailTemplate("{{ .Period", "")
+	suite.NotNil(err)
+}
+
+func (suite *MailPublisherTestSuite) TestValidAddresses() {
+
+	addresses := validAddresses([]string{"user@example.com", " John Doe <john@example.com> ", "invalid", "", "user@"}, loggerForTest())
+	suite.Equal([]string{"user@example.com", "\"John Doe\" <john@example.com>"}, addresses)
+}
+
+func (suite *MailPublisherTestSuite) TestContentType() {
+
+	suite.Equal("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", contentType("report.xlsx"))
+	suite.Equal("application/pdf", contentType("report.pdf"))
+	suite.Equal("text/html", contentType("report.html"))
+	suite.Equal("application/octet-stream", contentType("report"))
+}
+
+func (suite *MailPublisherTestSuite) TestBase64Lines() {
+
+	lines := strings.Split(string(base64Lines([]byte(strings.Repeat("x", 100)))), "\r\n")
+	suite.Len(lines, 2)
+	suite.Len(lines[0], 76)
+}
+
+// SesClientMock records all raw emails instead of sending them.
+type sesClientMock struct {
+	sesiface.SESAPI
+	inputs []*ses.SendRawEmailInput
+	err    error
+}
+
+func (mock *sesClientMock) SendRawEmail(input *ses.SendRawEmailInput) (*ses.SendRawEmailOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	mock.inputs = append(mock.inputs, input)
+	return &ses.SendRawEmailOutput{}, nil
+}
+
+func mailTemplatesForTest() mailTemplates {
+	return mailTemplates{"": mustMailTemplate(newMailTemplate("Report", "<p>Message</p>"))}
+}
+
+func generatedReportForTest() *generatedReport {
+	return &generatedReport{
+		Start:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:       time.Date(2022, 1, 31, 23, 59, 59, 0, time.UTC),
+		DeviceIds: []string{"Device01"},
+		Report: &timetracker.MonthlyReport{
+			Year:             2022,
+			Month:            1,
+			Days:             []timetracker.Day{},
+			TotalWorkingTime: 0,
+		},
+		Holidays: []timetracker.Holiday{},
+	}
+}
@@ -1,31 +1,77 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 
 	config "github.com/tommzn/go-config"
 	log "github.com/tommzn/go-log"
 	secrets "github.com/tommzn/go-secrets"
 	timetracker "github.com/tommzn/hob-timetracker"
+	"golang.org/x/crypto/ssh"
 )
 
 func main() {
 
-	handler, err := bootstrap()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			os.Exit(runGenerateCommand(os.Args[2:], os.Stdout, os.Stderr, bootstrap))
+		case "backfill":
+			os.Exit(runBackfillCommand(os.Args[2:], os.Stdout, os.Stderr, bootstrap))
+		}
+	}
+
+	mode := flag.String("mode", envOrDefault("HOB_MODE", "lambda"), "Run mode, lambda or server.")
+	address := flag.String("address", envOrDefault("HOB_SERVER_ADDRESS", "127.0.0.1:8080"), "Listen address in server mode.")
+	flag.Parse()
+
+	handler, err := bootstrap(nil)
 	if err != nil {
 		panic(err)
 	}
-	lambda.Start(handler.HandleEvents)
+
+	switch *mode {
+	case "server":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		server, err := newReportServer(handler, handler.logger)
+		if err != nil {
+			panic(err)
+		}
+		if err := server.ListenAndServe(ctx, *address); err != nil {
+			panic(err)
+		}
+	default:
+		lambda.Start(handler.HandleEvents)
+	}
+}
+
+// EnvOrDefault returns value of given environment variable, or passed default value if it's not set.
+func envOrDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
 }
 
 // bootstrap loads config and other dependencies to creates a reprt generator.
-func bootstrap() (*ReportGenerator, error) {
+// Passed config file is used if defined, otherwise config is loaded from S3.
+func bootstrap(configFile *string) (*ReportGenerator, error) {
 
-	conf, err := loadConfig()
+	conf, err := loadConfig(configFile)
 	if err != nil {
 		return nil, err
 	}
@@ -40,25 +86,41 @@ func bootstrap() (*ReportGenerator, error) {
 	timeTracker := newTimeTracker(awsConf)
 	deviceIds := deviceIds(conf)
 	locale := newLocale(conf)
-	calculator := newReportCalulator(locale)
-	calendar, err := newCalendar(conf, secretsManager, locale)
+	if err := validateTimezone(locale); err != nil {
+		return nil, err
+	}
+	calendar, err := newCalendar(conf, secretsManager, locale, logger)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyStore, idempotencyTtl, err := idempotencyStoreFromConfig(conf, awsConf)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ReportGenerator{
-		logger:      logger,
-		conf:        conf,
-		awsConf:     awsConf,
-		deviceIds:   deviceIds,
-		timeTracker: timeTracker,
-		calculator:  calculator,
-		calendar:    calendar,
+		logger:           logger,
+		conf:             conf,
+		awsConf:          awsConf,
+		deviceIds:        deviceIds,
+		timeTracker:      timeTracker,
+		calendar:         calendar,
+		secretsManager:   secretsManager,
+		locale:           locale,
+		idempotencyStore: idempotencyStore,
+		idempotencyTtl:   idempotencyTtl,
+		batchWorkers:     *conf.GetAsInt("hob.batch.workers", config.AsIntPtr(1)),
+		safetyMargin:     *conf.GetAsDuration("hob.batch.safety_margin", config.AsDurationPtr(10*time.Second)),
+		fetchWorkers:     *conf.GetAsInt("hob.timetracker.workers", config.AsIntPtr(4)),
 	}, nil
 }
 
-// loadConfig from config file.
-func loadConfig() (config.Config, error) {
+// loadConfig from given local file or from a config file in S3.
+func loadConfig(configFile *string) (config.Config, error) {
+
+	if configFile != nil {
+		return config.NewFileConfigSource(configFile).Load()
+	}
 
 	configSource, err := config.NewS3ConfigSourceFromEnv()
 	if err != nil {
@@ -124,9 +186,13 @@ func newLocale(conf config.Config) timetracker.Locale {
 	}
 }
 
-// NewReportCalulator will return a new report generator with given dependencies.
+// NewReportCalulator will return a new report calculator for given locale. Records are assigned to days in timezone of a locale.
 func newReportCalulator(location timetracker.Locale) timetracker.ReportCalculator {
-	return timetracker.NewReportCalulator([]timetracker.TimeTrackingRecord{}, location)
+	calculator := timetracker.NewReportCalulator([]timetracker.TimeTrackingRecord{}, location)
+	if timezone, err := loadTimezone(location); err == nil && timezone != nil {
+		return &localTimeCalculator{ReportCalculator: calculator, timezone: timezone}
+	}
+	return calculator
 }
 
 // DeviceIds extracts a list device ids from passed config.
@@ -141,12 +207,273 @@ func deviceIds(conf config.Config) []string {
 	return ids
 }
 
-// NewCalendar creates a new calendar api with given config and dependencies.
-// An api key is required. Passed secrets manager uses key HOB_CALENDAR_APIKEY to obtain it.
-func newCalendar(conf config.Config, secretsManager secrets.SecretsManager, location timetracker.Locale) (timetracker.Calendar, error) {
+// EmailAddresses returns all addresses from a list in config, e.g.
+//
+//	cc:
+//	  - address: hr@example.com
+func emailAddresses(conf config.Config, key string) []string {
+	addresses := []string{}
+	for _, addressConf := range conf.GetAsSliceOfMaps(key) {
+		if address, ok := addressConf["address"]; ok {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// MailTemplates returns all email templates from config, indexed by country. Subject and body
+// of default template are used for all entries which define one of them, only.
+//
+//	templates:
+//	  - country: de
+//	    subject: Arbeitszeitnachweis {{ .Period }}
+//	    body: <p>Anbei dein Arbeitszeitnachweis für {{ .Period }}.</p>
+func mailTemplatesFromConfig(conf config.Config) (mailTemplates, error) {
+	templates := mailTemplates{}
+	for _, templateConf := range conf.GetAsSliceOfMaps("hob.email.templates") {
+		subject, body := defaultMailSubject, defaultMailBody
+		if subjectConf, ok := templateConf["subject"]; ok {
+			subject = subjectConf
+		}
+		if bodyConf, ok := templateConf["body"]; ok {
+			body = bodyConf
+		}
+		tmpl, err := newMailTemplate(subject, body)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid email template for country %s, reason: %s", templateConf["country"], err)
+		}
+		templates[strings.ToUpper(templateConf["country"])] = tmpl
+	}
+	return templates, nil
+}
+
+// MailTransportFromConfig returns a transport for emails defined by config key hob.email.transport, ses or smtp.
+// AWS SES is used by default. For SMTP passed secrets manager has to provide HOB_SMTP_USER and HOB_SMTP_PASSWORD,
+// unless authentication is disabled.
+//
+//	email:
+//	  transport: smtp
+//	  smtp:
+//	    host: smtp.example.com
+//	    port: 587
+//	    tls: starttls
+//	    auth: plain
+//	    timeout: 10s
+func mailTransportFromConfig(conf config.Config, secretsManager secrets.SecretsManager) (mailTransport, error) {
+
+	transport := *conf.Get("hob.email.transport", config.AsStringPtr("ses"))
+	switch strings.ToLower(transport) {
+
+	case "ses":
+		return &sesTransport{}, nil
+
+	case "smtp":
+		host := conf.Get("hob.email.smtp.host", nil)
+		if host == nil {
+			return nil, errors.New("No SMTP host defined")
+		}
+		options := smtpOptions{
+			host:    *host,
+			port:    *conf.GetAsInt("hob.email.smtp.port", config.AsIntPtr(587)),
+			tls:     strings.ToLower(*conf.Get("hob.email.smtp.tls", config.AsStringPtr(smtpTlsStartTls))),
+			auth:    strings.ToLower(*conf.Get("hob.email.smtp.auth", config.AsStringPtr(smtpAuthPlain))),
+			timeout: *conf.GetAsDuration("hob.email.smtp.timeout", config.AsDurationPtr(10*time.Second)),
+		}
+		if options.tls != smtpTlsStartTls && options.tls != smtpTlsImplicit && options.tls != smtpTlsNone {
+			return nil, fmt.Errorf("Unsupported SMTP TLS mode: %s", options.tls)
+		}
+		if options.auth != smtpAuthPlain && options.auth != smtpAuthLogin && options.auth != smtpAuthNone {
+			return nil, fmt.Errorf("Unsupported SMTP authentication: %s", options.auth)
+		}
+		if options.auth != smtpAuthNone {
+			if secretsManager == nil {
+				return nil, errors.New("No secrets manager available to obtain SMTP credentials")
+			}
+			username, err := secretsManager.Obtain("HOB_SMTP_USER")
+			if err != nil {
+				return nil, err
+			}
+			password, err := secretsManager.Obtain("HOB_SMTP_PASSWORD")
+			if err != nil {
+				return nil, err
+			}
+			options.username, options.password = *username, *password
+		}
+		return newSmtpTransport(options), nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported email transport: %s", transport)
+	}
+}
+
+// WebhookPublisherFromConfig returns a publisher for a webhook defined in config, or nil if there's no webhook url.
+// If passed secrets manager provides HOB_WEBHOOK_SECRET, it's used to sign all requests. Requests are sent unsigned
+// only if there's no such secret, all other errors of a secrets manager are returned.
+//
+//	webhook:
+//	  url: https://example.com/reports
+//	  encoding: multipart
+//	  timeout: 10s
+//	  retries: 3
+//	  backoff: 1s
+//	  headers:
+//	    - name: X-Api-Key
+//	      value: xxx
+func webhookPublisherFromConfig(conf config.Config, secretsManager secrets.SecretsManager, logger log.Logger) (*webhookPublisher, error) {
+
+	url := conf.Get("hob.webhook.url", nil)
+	if url == nil {
+		return nil, nil
+	}
+
+	options := webhookOptions{
+		encoding: *conf.Get("hob.webhook.encoding", config.AsStringPtr(webhookEncodingJson)),
+		headers:  make(map[string]string),
+		timeout:  *conf.GetAsDuration("hob.webhook.timeout", config.AsDurationPtr(10*time.Second)),
+		retries:  *conf.GetAsInt("hob.webhook.retries", config.AsIntPtr(3)),
+		backoff:  *conf.GetAsDuration("hob.webhook.backoff", config.AsDurationPtr(1*time.Second)),
+	}
+	for _, headerConf := range conf.GetAsSliceOfMaps("hob.webhook.headers") {
+		name, ok1 := headerConf["name"]
+		value, ok2 := headerConf["value"]
+		if ok1 && ok2 {
+			options.headers[name] = value
+		}
+	}
+	if secretsManager != nil {
+		secret, err := secretsManager.Obtain("HOB_WEBHOOK_SECRET")
+		var notFound *secrets.SecretNotFoundError
+		if err != nil && !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("Unable to obtain webhook secret, reason: %s", err)
+		}
+		if err == nil {
+			options.secret = []byte(*secret)
+		}
+	}
+	if len(options.secret) == 0 {
+		logger.Debug("No webhook secret defined, requests will not be signed.")
+	}
+	return newWebhookPublisher(*url, options, logger), nil
+}
+
+// SftpPublisherFromConfig returns a publisher for a SFTP server defined in config, or nil if there's no SFTP host.
+// Passed secrets manager has to provide a private key, HOB_SFTP_PRIVATE_KEY, or a password, HOB_SFTP_PASSWORD.
+//
+//	sftp:
+//	  host: sftp.example.com
+//	  port: 22
+//	  user: reports
+//	  directory: /upload
+//	  fingerprint: SHA256:xxx
+func sftpPublisherFromConfig(conf config.Config, secretsManager secrets.SecretsManager, logger log.Logger) (*sftpPublisher, error) {
+
+	host := conf.Get("hob.sftp.host", nil)
+	if host == nil {
+		return nil, nil
+	}
+
+	options := sftpOptions{
+		host:        *host,
+		port:        *conf.GetAsInt("hob.sftp.port", config.AsIntPtr(22)),
+		user:        *conf.Get("hob.sftp.user", config.AsStringPtr("")),
+		directory:   *conf.Get("hob.sftp.directory", config.AsStringPtr(".")),
+		fingerprint: *conf.Get("hob.sftp.fingerprint", config.AsStringPtr("")),
+		auth:        []ssh.AuthMethod{},
+		timeout:     *conf.GetAsDuration("hob.sftp.timeout", config.AsDurationPtr(10*time.Second)),
+	}
+	if options.fingerprint == "" {
+		return nil, errors.New("No host key fingerprint defined for SFTP server")
+	}
+	if secretsManager != nil {
+		if privateKey, err := secretsManager.Obtain("HOB_SFTP_PRIVATE_KEY"); err == nil {
+			signer, err := ssh.ParsePrivateKey([]byte(*privateKey))
+			if err != nil {
+				return nil, err
+			}
+			options.auth = append(options.auth, ssh.PublicKeys(signer))
+		}
+		if password, err := secretsManager.Obtain("HOB_SFTP_PASSWORD"); err == nil {
+			options.auth = append(options.auth, ssh.Password(*password))
+		}
+	}
+	if len(options.auth) == 0 {
+		return nil, errors.New("No private key or password defined for SFTP server")
+	}
+	return newSftpPublisher(options, logger), nil
+}
+
+// IdempotencyStoreFromConfig returns a store for completion state of requests defined by config key hob.idempotency.store,
+// none, memory, file or s3. Returns no store by default. Completed requests are skipped within given TTL, default is 24h.
+// Bucket and region of AWS config are used if there's no bucket for a S3 store.
+//
+//	idempotency:
+//	  store: s3
+//	  bucket: reports
+//	  path: idempotency/
+//	  ttl: 24h
+func idempotencyStoreFromConfig(conf config.Config, awsConf awsConfig) (idempotencyStore, time.Duration, error) {
+
+	ttl := *conf.GetAsDuration("hob.idempotency.ttl", config.AsDurationPtr(24*time.Hour))
+	storeType := *conf.Get("hob.idempotency.store", config.AsStringPtr(idempotencyStoreNone))
+	switch strings.ToLower(storeType) {
+
+	case idempotencyStoreNone:
+		return nil, ttl, nil
+
+	case idempotencyStoreMemory:
+		return newMemoryIdempotencyStore(), ttl, nil
+
+	case idempotencyStoreFile:
+		path := conf.Get("hob.idempotency.path", nil)
+		if path == nil {
+			return nil, ttl, errors.New("No path defined for idempotency store")
+		}
+		return &fileIdempotencyStore{path: *path}, ttl, nil
+
+	case idempotencyStoreS3:
+		bucket := conf.Get("hob.idempotency.bucket", awsConf.bucket)
+		if bucket == nil {
+			return nil, ttl, errors.New("No S3 bucket defined for idempotency store")
+		}
+		return &s3IdempotencyStore{
+			client:   s3.New(session.Must(session.NewSession(&aws.Config{Region: awsConf.region}))),
+			bucket:   bucket,
+			basePath: *conf.Get("hob.idempotency.path", config.AsStringPtr("idempotency")),
+		}, ttl, nil
+
+	default:
+		return nil, ttl, fmt.Errorf("Unsupported idempotency store: %s", storeType)
+	}
+}
+
+// NewCalendar creates a calendar for country of given locale. Config key hob.calendar.provider defines whether
+// holidays are obtained from a calendar api, default, or calculated by an offline calendar. A calendar api requires
+// an api key, passed secrets manager uses key HOB_CALENDAR_APIKEY to obtain it. If a country is supported by
+// the offline calendar, it's used as fallback if there's no api key or if the api is unavailable.
+func newCalendar(conf config.Config, secretsManager secrets.SecretsManager, location timetracker.Locale, logger log.Logger) (timetracker.Calendar, error) {
+
+	provider := strings.ToLower(*conf.Get("hob.calendar.provider", config.AsStringPtr(calendarProviderApi)))
+	switch provider {
+	case calendarProviderOffline:
+		return newOfflineCalendar(location.Country)
+	case calendarProviderApi:
+	default:
+		return nil, fmt.Errorf("Unsupported calendar provider: %s", provider)
+	}
+
+	offlineCalendar, offlineErr := newOfflineCalendar(location.Country)
 	apiKey, err := secretsManager.Obtain("HOB_CALENDAR_APIKEY")
 	if err != nil {
-		return nil, err
+		if offlineErr != nil {
+			return nil, err
+		}
+		logger.Info("No calendar api key available, use offline calendar.")
+		return offlineCalendar, nil
+	}
+
+	apiCalendar := timetracker.NewCalendarApi(*apiKey, location)
+	if offlineErr != nil {
+		return apiCalendar, nil
 	}
-	return timetracker.NewCalendarApi(*apiKey, location), nil
+	return &fallbackCalendar{api: apiCalendar, offline: offlineCalendar, logger: logger}, nil
 }
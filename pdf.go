@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	log "github.com/tommzn/go-log"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+// NewPdfReportFormatter returns a new formatter to generate PDF files for reports.
+func newPdfReportFormatter(logger log.Logger) *pdfReportFormatter {
+	return &pdfReportFormatter{
+		holidays:   make(map[timetracker.Date]timetracker.Holiday),
+		dateFormat: "2006-01-02",
+		timeFormat: "15:04",
+		logger:     logger,
+	}
+}
+
+// PdfReportFormatter generates PDF files, ready to be signed and archived. Generated documents
+// are protected, they can be printed but not modified.
+type pdfReportFormatter struct {
+
+	// Holidays is a list of public holidays, indexed by date.
+	holidays map[timetracker.Date]timetracker.Holiday
+
+	// DateFormat is used for all dates in a report, if there's no date format in report locale.
+	dateFormat string
+
+	// TimeFormat defines output format for timestamps of time tracking records.
+	timeFormat string
+
+	logger log.Logger
+}
+
+// PdfDocument is a single PDF file together with settings used to write a report to it.
+type pdfDocument struct {
+	*fpdf.Fpdf
+
+	// Translate converts UTF-8 texts to encoding of used core fonts.
+	translate func(string) string
+
+	dateFormat string
+	timeFormat string
+	timezone   *time.Location
+}
+
+// PdfColumn is a single column of a table in a PDF document.
+type pdfColumn struct {
+	title string
+	width float64
+}
+
+// FileExtension returns file extension for PDF files: pdf.
+func (formatter *pdfReportFormatter) FileExtension() string {
+	return ".pdf"
+}
+
+// WithHolidays will assign give list of holidays for output formatting.
+func (formatter *pdfReportFormatter) WithHolidays(holidays []timetracker.Holiday) {
+	formatter.holidays = make(map[timetracker.Date]timetracker.Holiday)
+	for _, holiday := range holidays {
+		formatter.holidays[holiday.Date] = holiday
+	}
+}
+
+// WriteMonthlyReportToFile will generate a report output an writes it to given file.
+func (formatter *pdfReportFormatter) WriteMonthlyReportToFile(report *timetracker.MonthlyReport, filename string) error {
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// WriteMonthlyReportToBuffer returns a PDF document with all days of given monthly report.
+func (formatter *pdfReportFormatter) WriteMonthlyReportToBuffer(report *timetracker.MonthlyReport) (*bytes.Buffer, error) {
+	period := fmt.Sprintf("%04d-%02d", report.Year, report.Month)
+	return formatter.writeDays(period, daysInMonth(report), report.TotalWorkingTime, report.Location)
+}
+
+// WritePeriodReportToBuffer returns a PDF document with all days of given report.
+func (formatter *pdfReportFormatter) WritePeriodReportToBuffer(report *periodReport) (*bytes.Buffer, error) {
+	period := report.Start.Format("2006-01-02") + " - " + report.End.Format("2006-01-02")
+	return formatter.writeDays(period, report.Days, report.TotalWorkingTime, report.Location)
+}
+
+// WriteYearlyReportToBuffer returns a PDF document with a summary of each month of given yearly report.
+func (formatter *pdfReportFormatter) WriteYearlyReportToBuffer(report *yearlyReport) (*bytes.Buffer, error) {
+
+	doc := formatter.newDocument(report.Location)
+	doc.writeHeader(strconv.Itoa(report.Year), []string{}, report.Location)
+
+	columns := []pdfColumn{{"Month", 30}, {"Workdays", 25}, {"Working Time", 30}, {"Expected", 30}, {"Overtime", 30}, {"Balance", 35}}
+	doc.writeTableHeader(columns)
+	for _, month := range report.Months {
+		doc.writeRow(columns, []string{
+			fmt.Sprintf("%04d-%02d", report.Year, month.Month),
+			strconv.Itoa(month.Workdays),
+			formatDuration(month.WorkingTime),
+			formatDuration(month.ExpectedWorkingTime),
+			formatDuration(month.Overtime),
+			formatDuration(month.OvertimeBalance),
+		}, false)
+	}
+	doc.writeRow(columns, []string{"Total", "", formatDuration(report.TotalWorkingTime), formatDuration(report.ExpectedWorkingTime), formatDuration(report.Overtime), ""}, false)
+	doc.writeSignature()
+	return doc.output()
+}
+
+// WriteDays generates a PDF document with a header, a table with a row for each of passed days,
+// total working time and a signature line.
+func (formatter *pdfReportFormatter) writeDays(period string, days []timetracker.Day, totalWorkingTime time.Duration, locale timetracker.Locale) (*bytes.Buffer, error) {
+
+	doc := formatter.newDocument(locale)
+	doc.writeHeader(period, deviceIdsOf(days), locale)
+
+	columns := []pdfColumn{{"Date", 28}, {"Start", 20}, {"End", 20}, {"Break", 20}, {"Working Time", 27}, {"Comment", 65}}
+	doc.writeTableHeader(columns)
+	for _, day := range days {
+		start, end := "", ""
+		if len(day.Events) > 0 {
+			start = doc.formatTime(day.Events[0].Timestamp)
+		}
+		if len(day.Events) > 1 {
+			end = doc.formatTime(day.Events[len(day.Events)-1].Timestamp)
+		}
+		comment := ""
+		switch day.Type {
+		case timetracker.VACATION:
+			comment = "Vacation"
+		case timetracker.ILLNESS:
+			comment = "Illness"
+		}
+		holiday, isHoliday := formatter.holidays[day.Date]
+		if isHoliday {
+			comment = holiday.Description
+		}
+		weekday := day.Date.AsTime().Weekday()
+		highlight := isHoliday || weekday == time.Saturday || weekday == time.Sunday
+		doc.writeRow(columns, []string{day.Date.Format(doc.dateFormat), start, end, formatDuration(day.BreakTime), formatDuration(day.WorkingTime), comment}, highlight)
+	}
+	doc.writeRow(columns, []string{"Total", "", "", "", formatDuration(totalWorkingTime), ""}, false)
+	doc.writeSignature()
+	return doc.output()
+}
+
+// NewDocument creates a new, protected A4 PDF document with date format and timezone of given locale.
+func (formatter *pdfReportFormatter) newDocument(locale timetracker.Locale) *pdfDocument {
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetProtection(fpdf.CnProtectPrint, "", "")
+	pdf.SetCreator("hob-report-generator", true)
+	pdf.SetTitle("Time Tracking Report", true)
+	pdf.AddPage()
+
+	doc := &pdfDocument{
+		Fpdf:       pdf,
+		translate:  pdf.UnicodeTranslatorFromDescriptor(""),
+		dateFormat: formatter.dateFormat,
+		timeFormat: formatter.timeFormat,
+	}
+	if locale.DateFormat != nil {
+		doc.dateFormat = *locale.DateFormat
+	}
+	timezone, err := loadTimezone(locale)
+	if err != nil {
+		formatter.logger.Error("Unable to load location, reason: ", err)
+	}
+	doc.timezone = timezone
+	return doc
+}
+
+// WriteHeader adds a title together with report period, devices and locale.
+func (doc *pdfDocument) writeHeader(period string, deviceIds []string, locale timetracker.Locale) {
+
+	doc.SetFont("Helvetica", "B", 16)
+	doc.CellFormat(0, 10, doc.translate("Time Tracking Report"), "", 1, "L", false, 0, "")
+	doc.SetFont("Helvetica", "", 10)
+
+	if len(deviceIds) > 0 {
+		doc.CellFormat(0, 6, doc.translate("Employee / Device: "+strings.Join(deviceIds, ", ")), "", 1, "L", false, 0, "")
+	}
+	doc.CellFormat(0, 6, doc.translate("Period: "+period), "", 1, "L", false, 0, "")
+	localeDescription := locale.Country
+	if locale.Timezone != nil {
+		localeDescription += ", " + *locale.Timezone
+	}
+	doc.CellFormat(0, 6, doc.translate("Locale: "+localeDescription), "", 1, "L", false, 0, "")
+	doc.Ln(4)
+}
+
+// WriteTableHeader adds a bold headline for passed columns.
+func (doc *pdfDocument) writeTableHeader(columns []pdfColumn) {
+	doc.SetFont("Helvetica", "B", 10)
+	for _, column := range columns {
+		doc.CellFormat(column.width, 7, doc.translate(column.title), "B", 0, "L", false, 0, "")
+	}
+	doc.Ln(-1)
+	doc.SetFont("Helvetica", "", 10)
+}
+
+// WriteRow adds a table row with given values. Highlighted rows, e.g. holidays, are filled with a background color.
+func (doc *pdfDocument) writeRow(columns []pdfColumn, values []string, highlight bool) {
+	doc.SetFillColor(254, 215, 222)
+	for idx, column := range columns {
+		doc.CellFormat(column.width, 6, doc.translate(values[idx]), "", 0, "L", highlight, 0, "")
+	}
+	doc.Ln(-1)
+}
+
+// WriteSignature adds lines for date and signature of employee and supervisor.
+func (doc *pdfDocument) writeSignature() {
+	doc.Ln(20)
+	doc.CellFormat(80, 6, doc.translate("Date, Signature Employee"), "T", 0, "L", false, 0, "")
+	doc.CellFormat(20, 6, "", "", 0, "L", false, 0, "")
+	doc.CellFormat(80, 6, doc.translate("Date, Signature Supervisor"), "T", 1, "L", false, 0, "")
+}
+
+// FormatTime converts given timestamp to document timezone and applies time format.
+func (doc *pdfDocument) formatTime(t time.Time) string {
+	if doc.timezone != nil {
+		t = t.In(doc.timezone)
+	}
+	return t.Format(doc.timeFormat)
+}
+
+// Output writes current document to a buffer.
+func (doc *pdfDocument) output() (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	if err := doc.Output(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// DeviceIdsOf returns a sorted list of all devices time tracking records of passed days have been captured with.
+func deviceIdsOf(days []timetracker.Day) []string {
+	devices := make(map[string]bool)
+	for _, day := range days {
+		for _, event := range day.Events {
+			if event.DeviceId != "" {
+				devices[event.DeviceId] = true
+			}
+		}
+	}
+	deviceIds := []string{}
+	for deviceId := range devices {
+		deviceIds = append(deviceIds, deviceId)
+	}
+	sort.Strings(deviceIds)
+	return deviceIds
+}
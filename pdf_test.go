@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type PdfReportFormatterTestSuite struct {
+	suite.Suite
+}
+
+func TestPdfReportFormatterTestSuite(t *testing.T) {
+	suite.Run(t, new(PdfReportFormatterTestSuite))
+}
+
+func (suite *PdfReportFormatterTestSuite) TestWriteMonthlyReport() {
+
+	formatter := newPdfReportFormatter(loggerForTest())
+	formatter.WithHolidays([]timetracker.Holiday{
+		{Date: timetracker.Date{Year: 2022, Month: 1, Day: 6}, Description: "Heilige Drei Könige"},
+	})
+	report := &timetracker.MonthlyReport{
+		Year:     2022,
+		Month:    1,
+		Location: timetracker.Locale{Country: "DE", Timezone: asStringPtr("Europe/Berlin")},
+		Days: []timetracker.Day{
+			{
+				Date:        timetracker.Date{Year: 2022, Month: 1, Day: 3},
+				Type:        timetracker.WORKDAY,
+				WorkingTime: 8 * time.Hour,
+				BreakTime:   30 * time.Minute,
+				Events: []timetracker.TimeTrackingRecord{
+					{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 7, 0, 0, 0, time.UTC)},
+					{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: time.Date(2022, 1, 3, 15, 30, 0, 0, time.UTC)},
+				},
+			},
+		},
+		TotalWorkingTime: 8 * time.Hour,
+	}
+
+	buf, err := formatter.WriteMonthlyReportToBuffer(report)
+	suite.Nil(err)
+	suite.True(bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	suite.True(bytes.Contains(buf.Bytes(), []byte("/Encrypt")))
+	suite.Equal(".pdf", formatter.FileExtension())
+}
+
+func (suite *PdfReportFormatterTestSuite) TestWritePeriodAndYearlyReport() {
+
+	formatter := newPdfReportFormatter(loggerForTest())
+
+	buf1, err1 := formatter.WritePeriodReportToBuffer(periodReportForTest())
+	suite.Nil(err1)
+	suite.True(bytes.HasPrefix(buf1.Bytes(), []byte("%PDF-")))
+
+	buf2, err2 := formatter.WriteYearlyReportToBuffer(&yearlyReport{
+		Year:   2022,
+		Months: []monthlySummary{{Month: 1, Workdays: 21, WorkingTime: 170 * time.Hour, ExpectedWorkingTime: 168 * time.Hour, Overtime: 2 * time.Hour, OvertimeBalance: 2 * time.Hour}},
+	})
+	suite.Nil(err2)
+	suite.True(bytes.HasPrefix(buf2.Bytes(), []byte("%PDF-")))
+}
+
+func (suite *PdfReportFormatterTestSuite) TestDeviceIdsOf() {
+
+	days := []timetracker.Day{
+		{Events: []timetracker.TimeTrackingRecord{{DeviceId: "Device02"}, {DeviceId: "Device01"}}},
+		{Events: []timetracker.TimeTrackingRecord{{DeviceId: "Device02"}, {}}},
+	}
+	suite.Equal([]string{"Device01", "Device02"}, deviceIdsOf(days))
+}
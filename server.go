@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/tommzn/go-config"
+	log "github.com/tommzn/go-log"
+	core "github.com/tommzn/hob-core"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// MaxRequestSize is the max size of a report request body.
+const maxRequestSize = 1 << 20
+
+// ShutdownTimeout is the max time a server waits for running requests and jobs during shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// ServerTokenKey is the key of a token in secrets manager, clients have to send this token as bearer token.
+const serverTokenKey = "HOB_SERVER_TOKEN"
+
+// ErrTooManyJobs is returned if max number of asynchronous report jobs has been reached.
+var errTooManyJobs = errors.New("Too many report jobs!")
+
+// ErrServerClosed is returned for new asynchronous report jobs after a server has been shut down.
+var errServerClosed = errors.New("Server is shutting down!")
+
+// Status of asynchronous report jobs.
+const (
+	jobStatusPending = "pending"
+	jobStatusDone    = "done"
+	jobStatusFailed  = "failed"
+)
+
+// NewReportServer returns a http server for given report generator. Finished jobs are kept for a duration defined
+// by config key hob.server.job_ttl and number of jobs is limited by config key hob.server.max_jobs.
+// Secrets manager has to provide a token for clients, a server can't be created without it. Reports can only
+// be written to files in a directory defined by config key hob.server.file_directory.
+func newReportServer(handler *ReportGenerator, logger log.Logger) (*reportServer, error) {
+
+	token, err := handler.secretsManager.Obtain(serverTokenKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to obtain server token, reason: %s", err)
+	}
+	if token == nil || *token == "" {
+		return nil, errors.New("Server token must not be empty!")
+	}
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	return &reportServer{
+		handler:       handler,
+		token:         *token,
+		fileDirectory: handler.conf.Get("hob.server.file_directory", nil),
+		jobs:          make(map[string]*reportJob),
+		jobTtl:        *handler.conf.GetAsDuration("hob.server.job_ttl", config.AsDurationPtr(time.Hour)),
+		maxJobs:       *handler.conf.GetAsInt("hob.server.max_jobs", config.AsIntPtr(1000)),
+		jobsCtx:       jobsCtx,
+		cancelJobs:    cancelJobs,
+		logger:        logger,
+	}, nil
+}
+
+// ReportServer provides a http api to generate reports. Reports are returned synchronously
+// or generated by a background job, which delivers reports to all targets of a request.
+type reportServer struct {
+	handler *ReportGenerator
+
+	// Token has to be sent by clients as bearer token.
+	token string
+
+	// FileDirectory is the base directory of all file delivery targets. File delivery is rejected if it's not set.
+	fileDirectory *string
+
+	// Jobs are all asynchronous report jobs, indexed by their id.
+	jobs      map[string]*reportJob
+	jobsMutex sync.RWMutex
+
+	// JobTtl is the duration a finished job is kept.
+	jobTtl time.Duration
+
+	// MaxJobs is the max number of pending and finished jobs.
+	maxJobs int
+
+	// JobsCtx is used by all jobs, it's canceled if running jobs can't be completed during shutdown.
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+
+	// RunningJobs is used to wait for all jobs during shutdown.
+	runningJobs sync.WaitGroup
+
+	// Closed is set during shutdown, no further jobs are accepted.
+	closed bool
+
+	logger log.Logger
+}
+
+// ReportJob is an asynchronous report generation.
+type reportJob struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// FinishedAt is the time a job has been done or failed.
+	finishedAt time.Time
+}
+
+// ResponsePublisher collects all published reports to return them in a http response.
+type responsePublisher struct {
+	files []reportFile
+}
+
+// Send keeps given report.
+func (publisher *responsePublisher) Send(content []byte, fileName string) error {
+	publisher.files = append(publisher.files, reportFile{name: fileName, content: content})
+	return nil
+}
+
+// ListenAndServe starts a http server on given address. It stops if passed context is done and returns
+// after all running requests and jobs have been completed.
+func (server *reportServer) ListenAndServe(ctx context.Context, address string) error {
+
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+		server.drainJobs(shutdownCtx)
+	}()
+
+	server.logger.Debug("Listen on ", address)
+	server.logger.Flush()
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-shutdown
+	return nil
+}
+
+// DrainJobs rejects all further jobs and waits until running jobs have been completed. If passed context
+// is done before, running jobs are canceled.
+func (server *reportServer) drainJobs(ctx context.Context) {
+
+	server.jobsMutex.Lock()
+	server.closed = true
+	server.jobsMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		server.runningJobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		server.logger.Info("Shutdown timeout reached, cancel running jobs.")
+		server.cancelJobs()
+		<-done
+	}
+	server.logger.Flush()
+}
+
+// Routes returns a handler for all endpoints of report api.
+func (server *reportServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", server.handleHealth)
+	mux.HandleFunc("/reports", server.authorize(server.handleReports))
+	mux.HandleFunc("/reports/", server.authorize(server.handleJob))
+	return mux
+}
+
+// Authorize rejects all requests without a valid bearer token.
+func (server *reportServer) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(server.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// HandleHealth is used for liveness checks.
+func (server *reportServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// HandleReports generates a report for a GenerateReportRequest given as JSON. Additional report options can
+// be passed in field "options". By default generated reports are returned, if there're multiple report files
+// they're returned as ZIP archive. With query parameter async=true a job id is returned and a report is
+// generated in background, such a request needs a delivery target.
+func (server *reportServer) handleReports(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	request, err := readReportRequest(r)
+	if err == nil {
+		err = server.restrictDelivery(request)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+
+	if async {
+		if _, err := server.handler.newReportPublisher(request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job, err := server.newJob()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		go server.runJob(job, request)
+		writeJsonResponse(w, http.StatusAccepted, job)
+		return
+	}
+
+	publisher := &responsePublisher{}
+	err = server.handler.processRequest(r.Context(), request, publisher)
+	server.handler.logger.Flush()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	server.writeReport(w, publisher.files)
+}
+
+// HandleJob returns status of a background job.
+func (server *reportServer) handleJob(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server.jobsMutex.RLock()
+	job, ok := server.jobs[strings.TrimPrefix(r.URL.Path, "/reports/")]
+	var status reportJob
+	if ok {
+		status = *job
+	}
+	server.jobsMutex.RUnlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, status)
+}
+
+// NewJob creates a pending job with a random id. Expired jobs are removed before. Returns with an error if max number
+// of jobs has been reached or if a server is shutting down.
+func (server *reportServer) newJob() (reportJob, error) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	job := &reportJob{Id: hex.EncodeToString(id), Status: jobStatusPending}
+
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	if server.closed {
+		return reportJob{}, errServerClosed
+	}
+	server.evictJobs()
+	if len(server.jobs) >= server.maxJobs {
+		return reportJob{}, errTooManyJobs
+	}
+	server.jobs[job.Id] = job
+	server.runningJobs.Add(1)
+	return *job, nil
+}
+
+// EvictJobs removes all jobs which have been finished before job ttl. Caller has to hold the jobs lock.
+func (server *reportServer) evictJobs() {
+	for id, job := range server.jobs {
+		if job.Status != jobStatusPending && time.Since(job.finishedAt) > server.jobTtl {
+			delete(server.jobs, id)
+		}
+	}
+}
+
+// RunJob generates a report for given request and updates job status afterwards. It doesn't use the context of
+// a http request, because a job is still running after a response has been sent. Jobs are canceled if they can't
+// be completed during shutdown of a server.
+func (server *reportServer) runJob(job reportJob, request *reportRequest) {
+
+	defer server.runningJobs.Done()
+	err := server.handler.processRequest(server.jobsCtx, request)
+	server.handler.logger.Flush()
+
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	server.jobs[job.Id].finishedAt = time.Now()
+	if err != nil {
+		server.jobs[job.Id].Status = jobStatusFailed
+		server.jobs[job.Id].Error = err.Error()
+	} else {
+		server.jobs[job.Id].Status = jobStatusDone
+	}
+}
+
+// WriteReport writes a single report file, or a ZIP archive for multiple files, to given response.
+func (server *reportServer) writeReport(w http.ResponseWriter, files []reportFile) {
+
+	if len(files) == 0 {
+		http.Error(w, "No report generated", http.StatusInternalServerError)
+		return
+	}
+	file := files[0]
+	if len(files) > 1 {
+		archive, err := newReportArchive(files)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		file = reportFile{name: strings.TrimSuffix(files[0].name, filepath.Ext(files[0].name)) + archiveFileExtension, content: archive.Bytes()}
+	}
+	w.Header().Set("Content-Type", contentType(file.name))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.name))
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.content)
+}
+
+// ReadReportRequest decodes a GenerateReportRequest and report options from a request body.
+// A request without delivery target is accepted, reports will be returned in a response in this case.
+func readReportRequest(r *http.Request) (*reportRequest, error) {
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestSize))
+	if err != nil {
+		return nil, err
+	}
+
+	request := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{}}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, request.GenerateReportRequest); err != nil {
+		return nil, err
+	}
+	envelope := struct {
+		Options reportOptions `json:"options"`
+	}{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	request.reportOptions = envelope.Options
+	if request.Delivery == nil {
+		request.Delivery = &core.ReportDelivery{}
+	}
+	return request, nil
+}
+
+// RestrictDelivery ensures reports of a request are only written to files in file directory of a server, a file
+// path of a request is relative to this directory. Reports can't be uploaded to other S3 buckets than defined in config.
+func (server *reportServer) restrictDelivery(request *reportRequest) error {
+
+	for _, element := range strings.Split(filepath.ToSlash(request.NamePattern), "/") {
+		if element == ".." {
+			return fmt.Errorf("Invalid name pattern: %s", request.NamePattern)
+		}
+	}
+
+	if file := request.Delivery.File; file != nil {
+		if server.fileDirectory == nil {
+			return errors.New("File delivery is not allowed!")
+		}
+		path := filepath.Join(*server.fileDirectory, file.Path)
+		relativePath, err := filepath.Rel(*server.fileDirectory, path)
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("File path %s is outside of file directory!", file.Path)
+		}
+		file.Path = path + string(filepath.Separator)
+	}
+
+	if target := request.Delivery.S3; target != nil && target.Bucket != "" {
+		if bucket := server.handler.awsConf.bucket; bucket == nil || *bucket != target.Bucket {
+			return fmt.Errorf("S3 bucket %s is not allowed!", target.Bucket)
+		}
+	}
+	return nil
+}
+
+// WriteJsonResponse encodes given value as JSON response body.
+func writeJsonResponse(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	config "github.com/tommzn/go-config"
+	secrets "github.com/tommzn/go-secrets"
+	core "github.com/tommzn/hob-core"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const serverTokenForTest = "test-token"
+
+type ReportServerTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func TestReportServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ReportServerTestSuite))
+}
+
+func (suite *ReportServerTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(suite.reportServer(reportGeneratorForTest()).routes())
+}
+
+func (suite *ReportServerTestSuite) serve(server *reportServer) {
+	suite.server.Close()
+	suite.server = httptest.NewServer(server.routes())
+}
+
+func (suite *ReportServerTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *ReportServerTestSuite) TestHealth() {
+
+	response, err := http.Get(suite.server.URL + "/healthz")
+	suite.Nil(err)
+	defer response.Body.Close()
+	suite.Equal(http.StatusOK, response.StatusCode)
+
+	response2, err := http.Post(suite.server.URL+"/healthz", "application/json", nil)
+	suite.Nil(err)
+	defer response2.Body.Close()
+	suite.Equal(http.StatusMethodNotAllowed, response2.StatusCode)
+}
+
+func (suite *ReportServerTestSuite) TestGenerateReport() {
+
+	response := suite.post("/reports", `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1,"NamePattern":"TestReport_200601"}`)
+	defer response.Body.Close()
+	suite.Equal(http.StatusOK, response.StatusCode)
+	suite.Equal("text/csv", response.Header.Get("Content-Type"))
+	suite.Equal("attachment; filename=\"TestReport_202201.csv\"", response.Header.Get("Content-Disposition"))
+}
+
+func (suite *ReportServerTestSuite) TestGenerateReportInMultipleFormats() {
+
+	response := suite.post("/reports", `{"Type":"MONTHLY_REPORT","Format":"EXCEL","Year":2022,"Month":1,"NamePattern":"TestReport_200601","options":{"formats":["csv"]}}`)
+	defer response.Body.Close()
+	suite.Equal(http.StatusOK, response.StatusCode)
+	suite.Equal("application/zip", response.Header.Get("Content-Type"))
+
+	body := new(bytes.Buffer)
+	body.ReadFrom(response.Body)
+	archive, err := zip.NewReader(bytes.NewReader(body.Bytes()), int64(body.Len()))
+	suite.Nil(err)
+	fileNames := []string{}
+	for _, file := range archive.File {
+		fileNames = append(fileNames, file.Name)
+	}
+	suite.Contains(fileNames, "TestReport_202201.csv")
+	suite.Contains(fileNames, "TestReport_202201.xlsx")
+}
+
+func (suite *ReportServerTestSuite) TestConcurrentRequests() {
+
+	responses := make(chan *http.Response, 6)
+	for month := 1; month <= cap(responses); month++ {
+		go func(month int) {
+			responses <- suite.post("/reports", fmt.Sprintf(`{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":%d,"NamePattern":"TestReport_200601"}`, month))
+		}(month)
+	}
+
+	fileNames := []string{}
+	for idx := 0; idx < cap(responses); idx++ {
+		response := <-responses
+		suite.Equal(http.StatusOK, response.StatusCode)
+		fileNames = append(fileNames, response.Header.Get("Content-Disposition"))
+		response.Body.Close()
+	}
+	for month := 1; month <= cap(responses); month++ {
+		suite.Contains(fileNames, fmt.Sprintf("attachment; filename=\"TestReport_2022%02d.csv\"", month))
+	}
+}
+
+func (suite *ReportServerTestSuite) TestInvalidRequest() {
+
+	response1 := suite.post("/reports", `{"Type":`)
+	defer response1.Body.Close()
+	suite.Equal(http.StatusBadRequest, response1.StatusCode)
+
+	response2 := suite.get("/reports")
+	defer response2.Body.Close()
+	suite.Equal(http.StatusMethodNotAllowed, response2.StatusCode)
+
+	response3 := suite.post("/reports", `{"Type":"MONTHLY_REPORT","Format":"NO_FORMAT","Year":2022,"Month":1}`)
+	defer response3.Body.Close()
+	suite.Equal(http.StatusInternalServerError, response3.StatusCode)
+}
+
+func (suite *ReportServerTestSuite) TestAsyncReportGeneration() {
+
+	response1 := suite.post("/reports?async=true", `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1}`)
+	defer response1.Body.Close()
+	suite.Equal(http.StatusBadRequest, response1.StatusCode)
+
+	path := suite.T().TempDir()
+	server := suite.reportServer(reportGeneratorForTest())
+	server.fileDirectory = &path
+	suite.serve(server)
+	response2 := suite.post("/reports?async=true", `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1,"NamePattern":"TestReport_200601","Delivery":{"File":{"Path":"/"}}}`)
+	defer response2.Body.Close()
+	suite.Equal(http.StatusAccepted, response2.StatusCode)
+
+	job := reportJob{}
+	suite.Nil(json.NewDecoder(response2.Body).Decode(&job))
+	suite.NotEqual("", job.Id)
+	suite.Equal(jobStatusPending, job.Status)
+
+	suite.Eventually(func() bool {
+		return suite.jobStatus(job.Id).Status == jobStatusDone
+	}, 5*time.Second, 10*time.Millisecond)
+	suite.FileExists(path + "/TestReport_202201.csv")
+
+	response3 := suite.get("/reports/unknown")
+	defer response3.Body.Close()
+	suite.Equal(http.StatusNotFound, response3.StatusCode)
+}
+
+func (suite *ReportServerTestSuite) TestSyncRequestWithoutDeliveryTargets() {
+
+	var requests int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer webhook.Close()
+
+	conf, err := config.NewStaticConfigSource(fmt.Sprintf("hob:\n  webhook:\n    url: %s\n", webhook.URL)).Load()
+	suite.Nil(err)
+	handler := reportGeneratorForTest()
+	handler.conf = conf
+	suite.serve(suite.reportServer(handler))
+
+	response1 := suite.post("/reports", `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1,"NamePattern":"TestReport_200601"}`)
+	defer response1.Body.Close()
+	suite.Equal(http.StatusOK, response1.StatusCode)
+	suite.Equal(int32(0), atomic.LoadInt32(&requests))
+
+	response2 := suite.post("/reports", `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1,"NamePattern":"TestReport_200601","options":{"targets":["webhook"]}}`)
+	defer response2.Body.Close()
+	suite.Equal(http.StatusOK, response2.StatusCode)
+	suite.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func (suite *ReportServerTestSuite) TestAuthorization() {
+
+	body := `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1,"NamePattern":"TestReport_200601"}`
+	response1, err := http.Post(suite.server.URL+"/reports", "application/json", strings.NewReader(body))
+	suite.Nil(err)
+	defer response1.Body.Close()
+	suite.Equal(http.StatusUnauthorized, response1.StatusCode)
+
+	for _, header := range []string{serverTokenForTest, "Bearer invalid", "Basic " + serverTokenForTest} {
+		request, err := http.NewRequest(http.MethodGet, suite.server.URL+"/reports/unknown", nil)
+		suite.Nil(err)
+		request.Header.Set("Authorization", header)
+		response, err := http.DefaultClient.Do(request)
+		suite.Nil(err)
+		response.Body.Close()
+		suite.Equal(http.StatusUnauthorized, response.StatusCode, header)
+	}
+}
+
+func (suite *ReportServerTestSuite) TestNewServerWithoutToken() {
+
+	handler := reportGeneratorForTest()
+	handler.secretsManager = secrets.NewStaticSecretsManager(map[string]string{})
+	_, err1 := newReportServer(handler, loggerForTest())
+	suite.NotNil(err1)
+
+	handler.secretsManager = secrets.NewStaticSecretsManager(map[string]string{serverTokenKey: ""})
+	_, err2 := newReportServer(handler, loggerForTest())
+	suite.NotNil(err2)
+}
+
+func (suite *ReportServerTestSuite) TestRestrictDelivery() {
+
+	response1 := suite.post("/reports", `{"Type":"MONTHLY_REPORT","Format":2,"Year":2022,"Month":1,"Delivery":{"File":{"Path":"/tmp/"}}}`)
+	defer response1.Body.Close()
+	suite.Equal(http.StatusBadRequest, response1.StatusCode)
+
+	path := suite.T().TempDir()
+	bucket := "report-bucket"
+	handler := reportGeneratorForTest()
+	handler.awsConf.bucket = &bucket
+	server := suite.reportServer(handler)
+	server.fileDirectory = &path
+
+	request := func(delivery string) *reportRequest {
+		request := &reportRequest{GenerateReportRequest: &core.GenerateReportRequest{NamePattern: "TestReport_200601"}}
+		suite.Require().Nil(protojson.Unmarshal([]byte(delivery), request.GenerateReportRequest))
+		return request
+	}
+
+	request1 := request(`{"Delivery":{"File":{"Path":"/2022/"}}}`)
+	suite.Nil(server.restrictDelivery(request1))
+	suite.Equal(path+"/2022/", request1.Delivery.File.Path)
+
+	suite.NotNil(server.restrictDelivery(request(`{"Delivery":{"File":{"Path":"../"}}}`)))
+	suite.NotNil(server.restrictDelivery(request(`{"Delivery":{"File":{"Path":"2022/../../"}}}`)))
+	suite.NotNil(server.restrictDelivery(request(`{"NamePattern":"../TestReport_200601","Delivery":{"File":{"Path":"/"}}}`)))
+
+	suite.Nil(server.restrictDelivery(request(`{"Delivery":{"S3":{"Bucket":"report-bucket"}}}`)))
+	suite.NotNil(server.restrictDelivery(request(`{"Delivery":{"S3":{"Bucket":"other-bucket"}}}`)))
+}
+
+func (suite *ReportServerTestSuite) TestEvictJobs() {
+
+	server := suite.reportServer(reportGeneratorForTest())
+	server.maxJobs = 2
+	job1, err1 := server.newJob()
+	suite.Nil(err1)
+	_, err2 := server.newJob()
+	suite.Nil(err2)
+	_, err3 := server.newJob()
+	suite.Equal(errTooManyJobs, err3)
+
+	server.jobs[job1.Id].Status = jobStatusDone
+	server.jobs[job1.Id].finishedAt = time.Now().Add(-2 * server.jobTtl)
+	_, err4 := server.newJob()
+	suite.Nil(err4)
+	suite.Len(server.jobs, 2)
+	suite.NotContains(server.jobs, job1.Id)
+}
+
+func (suite *ReportServerTestSuite) TestDrainJobsOnShutdown() {
+
+	handler := reportGeneratorForTest()
+	handler.timeTracker = &timeTrackerMock{TimeTracker: handler.timeTracker, delay: 100 * time.Millisecond}
+	server := suite.reportServer(handler)
+	path := suite.T().TempDir() + "/"
+	job, err := server.newJob()
+	suite.Nil(err)
+	go server.runJob(job, &reportRequest{GenerateReportRequest: eventForTest(path)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	suite.Nil(server.ListenAndServe(ctx, "127.0.0.1:0"))
+	suite.Equal(jobStatusDone, server.jobs[job.Id].Status)
+	suite.FileExists(path + "TestReport_202201.xlsx")
+
+	_, err = server.newJob()
+	suite.Equal(errServerClosed, err)
+}
+
+func (suite *ReportServerTestSuite) TestCancelJobsAfterShutdownTimeout() {
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	handler := reportGeneratorForTest()
+	handler.timeTracker = &timeTrackerMock{TimeTracker: handler.timeTracker, blocked: blocked}
+	server := suite.reportServer(handler)
+	job, err := server.newJob()
+	suite.Nil(err)
+	go server.runJob(job, &reportRequest{GenerateReportRequest: eventForTest(suite.T().TempDir() + "/")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	server.drainJobs(ctx)
+	suite.Equal(jobStatusFailed, server.jobs[job.Id].Status)
+}
+
+func (suite *ReportServerTestSuite) reportServer(handler *ReportGenerator) *reportServer {
+	handler.secretsManager = secrets.NewStaticSecretsManager(map[string]string{serverTokenKey: serverTokenForTest})
+	server, err := newReportServer(handler, loggerForTest())
+	suite.Require().Nil(err)
+	return server
+}
+
+func (suite *ReportServerTestSuite) post(path, body string) *http.Response {
+	request, err := http.NewRequest(http.MethodPost, suite.server.URL+path, strings.NewReader(body))
+	suite.Require().Nil(err)
+	request.Header.Set("Content-Type", "application/json")
+	return suite.send(request)
+}
+
+func (suite *ReportServerTestSuite) get(path string) *http.Response {
+	request, err := http.NewRequest(http.MethodGet, suite.server.URL+path, nil)
+	suite.Require().Nil(err)
+	return suite.send(request)
+}
+
+func (suite *ReportServerTestSuite) send(request *http.Request) *http.Response {
+	request.Header.Set("Authorization", "Bearer "+serverTokenForTest)
+	response, err := http.DefaultClient.Do(request)
+	suite.Require().Nil(err)
+	return response
+}
+
+func (suite *ReportServerTestSuite) jobStatus(id string) reportJob {
+	response := suite.get("/reports/" + id)
+	defer response.Body.Close()
+	job := reportJob{}
+	json.NewDecoder(response.Body).Decode(&job)
+	return job
+}
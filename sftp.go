@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	log "github.com/tommzn/go-log"
+	"golang.org/x/crypto/ssh"
+)
+
+// NewSftpPublisher returns a publisher which uploads reports to a SFTP server.
+func newSftpPublisher(options sftpOptions, logger log.Logger) *sftpPublisher {
+	return &sftpPublisher{
+		options: options,
+		logger:  logger,
+	}
+}
+
+// SftpOptions define server, credentials and remote directory for SFTP uploads.
+type sftpOptions struct {
+	host string
+	port int
+	user string
+
+	// Directory is a remote directory all reports are uploaded to.
+	directory string
+
+	// Fingerprint is a SHA256 fingerprint of server host key, e.g. SHA256:xxx. Connections to a server
+	// with a different host key are rejected.
+	fingerprint string
+
+	// Auth contains a private key or a password to authenticate at a server.
+	auth []ssh.AuthMethod
+
+	// Timeout for establishing a connection.
+	timeout time.Duration
+}
+
+// SftpPublisher uploads reports to a remote directory of a SFTP server. Each report is uploaded
+// with a temporary name and renamed afterwards, so a partial upload is never visible with its final name.
+type sftpPublisher struct {
+	options sftpOptions
+	logger  log.Logger
+}
+
+// Send uploads given report to remote directory.
+func (publisher *sftpPublisher) Send(content []byte, fileName string) error {
+
+	client, err := publisher.connect()
+	if err != nil {
+		publisher.logger.Error("Unable to connect to SFTP server, reason: ", err)
+		return err
+	}
+	defer client.Close()
+
+	targetFile := path.Join(publisher.options.directory, fileName)
+	tempFile := hiddenFile(targetFile, ".tmp")
+	if err := client.MkdirAll(path.Dir(targetFile)); err != nil {
+		publisher.logger.Error("Unable to create remote directory, reason: ", err)
+		return err
+	}
+	if err := upload(client, tempFile, content); err != nil {
+		client.Remove(tempFile)
+		publisher.logger.Error("Unable to upload report to SFTP server, reason: ", err)
+		return err
+	}
+	if err := replaceFile(client, tempFile, targetFile); err != nil {
+		client.Remove(tempFile)
+		publisher.logger.Error("Unable to rename uploaded report, reason: ", err)
+		return err
+	}
+	publisher.logger.Debug("Report successful uploaded to SFTP server: ", targetFile)
+	return nil
+}
+
+// Location returns an url of remote directory, which has to be completed by a file name.
+func (publisher *sftpPublisher) location() string {
+	address := net.JoinHostPort(publisher.options.host, strconv.Itoa(publisher.options.port))
+	return "sftp://" + address + strings.TrimSuffix(path.Join("/", publisher.options.directory), "/") + "/"
+}
+
+// Connect opens a new SSH connection and starts a SFTP session.
+func (publisher *sftpPublisher) connect() (*sftpConnection, error) {
+
+	sshConfig := &ssh.ClientConfig{
+		User:            publisher.options.user,
+		Auth:            publisher.options.auth,
+		HostKeyCallback: fingerprintCallback(publisher.options.fingerprint),
+		Timeout:         publisher.options.timeout,
+	}
+	address := net.JoinHostPort(publisher.options.host, strconv.Itoa(publisher.options.port))
+	sshClient, err := ssh.Dial("tcp", address, sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	return &sftpConnection{Client: sftpClient, sshClient: sshClient}, nil
+}
+
+// SftpConnection is a SFTP session together with its underlying SSH connection.
+type sftpConnection struct {
+	*sftp.Client
+	sshClient *ssh.Client
+}
+
+// Close ends SFTP session and SSH connection.
+func (connection *sftpConnection) Close() error {
+	connection.Client.Close()
+	return connection.sshClient.Close()
+}
+
+// SftpFileSystem contains all operations of a SFTP client which are used to replace a remote file.
+type sftpFileSystem interface {
+	PosixRename(oldname, newname string) error
+	Rename(oldname, newname string) error
+	Remove(path string) error
+}
+
+// ReplaceFile renames given temporary file to target file. POSIX rename replaces an existing file in a single step,
+// it's supported by OpenSSH as an extension. Otherwise an existing file is moved to a backup file first and restored
+// if renaming the temporary file fails, so a previously delivered report is never lost.
+func replaceFile(client sftpFileSystem, tempFile, targetFile string) error {
+
+	if err := client.PosixRename(tempFile, targetFile); err == nil {
+		return nil
+	}
+
+	backupFile := hiddenFile(targetFile, ".bak")
+	client.Remove(backupFile)
+	hasBackup := client.Rename(targetFile, backupFile) == nil
+	if err := client.Rename(tempFile, targetFile); err != nil {
+		if hasBackup {
+			client.Rename(backupFile, targetFile)
+		}
+		return err
+	}
+	if hasBackup {
+		client.Remove(backupFile)
+	}
+	return nil
+}
+
+// HiddenFile returns a hidden file with given suffix in same directory as passed file, e.g. 2022/.report.csv.tmp
+// for 2022/report.csv.
+func hiddenFile(fileName, suffix string) string {
+	return path.Join(path.Dir(fileName), "."+path.Base(fileName)+suffix)
+}
+
+// Upload writes given content to a remote file.
+func upload(client *sftpConnection, fileName string, content []byte) error {
+	file, err := client.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, bytes.NewReader(content)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// FingerprintCallback accepts host keys with given SHA256 fingerprint, only.
+func fingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if fingerprint == "" {
+			return errors.New("No host key fingerprint defined")
+		}
+		if actual := ssh.FingerprintSHA256(key); actual != fingerprint {
+			return fmt.Errorf("Host key fingerprint mismatch for %s, got %s", hostname, actual)
+		}
+		return nil
+	}
+}
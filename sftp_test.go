@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/suite"
+	config "github.com/tommzn/go-config"
+	secrets "github.com/tommzn/go-secrets"
+	"golang.org/x/crypto/ssh"
+)
+
+type SftpPublisherTestSuite struct {
+	suite.Suite
+	hostKey  ssh.Signer
+	listener net.Listener
+	dir      string
+}
+
+func TestSftpPublisherTestSuite(t *testing.T) {
+	suite.Run(t, new(SftpPublisherTestSuite))
+}
+
+func (suite *SftpPublisherTestSuite) SetupTest() {
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	suite.Nil(err)
+	suite.hostKey, err = ssh.NewSignerFromKey(privateKey)
+	suite.Nil(err)
+	suite.dir = suite.T().TempDir()
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "reports" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	serverConfig.AddHostKey(suite.hostKey)
+
+	suite.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	suite.Nil(err)
+	go serveSftpForTest(suite.listener, serverConfig)
+}
+
+func (suite *SftpPublisherTestSuite) TearDownTest() {
+	suite.listener.Close()
+}
+
+func (suite *SftpPublisherTestSuite) TestUpload() {
+
+	publisher := newSftpPublisher(suite.optionsForTest(), loggerForTest())
+	suite.Nil(publisher.Send([]byte("report"), "report_202201.csv"))
+	suite.assertFileContent("report_202201.csv", "report")
+
+	suite.Nil(publisher.Send([]byte("report v2"), "report_202201.csv"))
+	suite.assertFileContent("report_202201.csv", "report v2")
+
+	files, err := os.ReadDir(suite.dir)
+	suite.Nil(err)
+	suite.Len(files, 1)
+}
+
+func (suite *SftpPublisherTestSuite) TestUploadWithNestedName() {
+
+	publisher := newSftpPublisher(suite.optionsForTest(), loggerForTest())
+	suite.Nil(publisher.Send([]byte("report"), "2022/report_202201.csv"))
+	suite.assertFileContent("2022/report_202201.csv", "report")
+
+	suite.Nil(publisher.Send([]byte("report v2"), "2022/report_202201.csv"))
+	suite.assertFileContent("2022/report_202201.csv", "report v2")
+
+	files, err := os.ReadDir(filepath.Join(suite.dir, "2022"))
+	suite.Nil(err)
+	suite.Len(files, 1)
+}
+
+func (suite *SftpPublisherTestSuite) TestReplaceFileWithoutPosixRename() {
+
+	fileSystem := &sftpFileSystemMock{files: map[string]string{"/upload/.report.csv.tmp": "new"}}
+	suite.Nil(replaceFile(fileSystem, "/upload/.report.csv.tmp", "/upload/report.csv"))
+	suite.Equal(map[string]string{"/upload/report.csv": "new"}, fileSystem.files)
+
+	fileSystem.files["/upload/.report.csv.tmp"] = "v2"
+	suite.Nil(replaceFile(fileSystem, "/upload/.report.csv.tmp", "/upload/report.csv"))
+	suite.Equal(map[string]string{"/upload/report.csv": "v2"}, fileSystem.files)
+
+	// Existing report is restored if a new report can't be renamed.
+	fileSystem.files["/upload/.report.csv.tmp"] = "v3"
+	fileSystem.failingRename = "/upload/.report.csv.tmp"
+	suite.NotNil(replaceFile(fileSystem, "/upload/.report.csv.tmp", "/upload/report.csv"))
+	suite.Equal("v2", fileSystem.files["/upload/report.csv"])
+	suite.NotContains(fileSystem.files, "/upload/.report.csv.bak")
+}
+
+func (suite *SftpPublisherTestSuite) TestHiddenFile() {
+	suite.Equal("/upload/.report.csv.tmp", hiddenFile("/upload/report.csv", ".tmp"))
+	suite.Equal("/upload/2022/.report.csv.tmp", hiddenFile("/upload/2022/report.csv", ".tmp"))
+}
+
+func (suite *SftpPublisherTestSuite) TestRejectUnknownHostKey() {
+
+	options := suite.optionsForTest()
+	options.fingerprint = "SHA256:unknown"
+	publisher := newSftpPublisher(options, loggerForTest())
+	suite.NotNil(publisher.Send([]byte("report"), "report_202201.csv"))
+
+	options.fingerprint = ""
+	publisher = newSftpPublisher(options, loggerForTest())
+	suite.NotNil(publisher.Send([]byte("report"), "report_202201.csv"))
+}
+
+func (suite *SftpPublisherTestSuite) TestInvalidCredentials() {
+
+	options := suite.optionsForTest()
+	options.auth = []ssh.AuthMethod{ssh.Password("invalid")}
+	publisher := newSftpPublisher(options, loggerForTest())
+	suite.NotNil(publisher.Send([]byte("report"), "report_202201.csv"))
+}
+
+func (suite *SftpPublisherTestSuite) TestLocation() {
+
+	options := suite.optionsForTest()
+	options.host = "sftp.example.com"
+	options.port = 22
+	options.directory = "upload/"
+	suite.Equal("sftp://sftp.example.com:22/upload/", newSftpPublisher(options, loggerForTest()).location())
+}
+
+func (suite *SftpPublisherTestSuite) TestSftpPublisherFromConfig() {
+
+	publisher, err := sftpPublisherFromConfig(emptyConfigForTest(), secretsManagerForTest(), loggerForTest())
+	suite.Nil(publisher)
+	suite.Nil(err)
+
+	conf, err := config.NewStaticConfigSource(`
+hob:
+  sftp:
+    host: sftp.example.com
+    user: reports
+    directory: /upload
+    fingerprint: SHA256:xxx
+`).Load()
+	suite.Nil(err)
+
+	_, err = sftpPublisherFromConfig(conf, secrets.NewStaticSecretsManager(map[string]string{}), loggerForTest())
+	suite.NotNil(err)
+
+	_, clientKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	suite.Nil(err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Key})
+	secretsManager := secrets.NewStaticSecretsManager(map[string]string{"HOB_SFTP_PRIVATE_KEY": string(privateKey)})
+	publisher, err = sftpPublisherFromConfig(conf, secretsManager, loggerForTest())
+	suite.Nil(err)
+	suite.Equal("sftp.example.com", publisher.options.host)
+	suite.Equal(22, publisher.options.port)
+	suite.Equal("/upload", publisher.options.directory)
+	suite.Len(publisher.options.auth, 1)
+
+	_, err = sftpPublisherFromConfig(conf, secrets.NewStaticSecretsManager(map[string]string{"HOB_SFTP_PRIVATE_KEY": "xxx"}), loggerForTest())
+	suite.NotNil(err)
+}
+
+func (suite *SftpPublisherTestSuite) optionsForTest() sftpOptions {
+	address := suite.listener.Addr().(*net.TCPAddr)
+	return sftpOptions{
+		host:        address.IP.String(),
+		port:        address.Port,
+		user:        "reports",
+		directory:   suite.dir,
+		fingerprint: ssh.FingerprintSHA256(suite.hostKey.PublicKey()),
+		auth:        []ssh.AuthMethod{ssh.Password("secret")},
+		timeout:     time.Second,
+	}
+}
+
+func (suite *SftpPublisherTestSuite) assertFileContent(fileName, expectedContent string) {
+	content, err := os.ReadFile(filepath.Join(suite.dir, fileName))
+	suite.Nil(err)
+	suite.Equal(expectedContent, string(content))
+}
+
+// ServeSftpForTest accepts SSH connections and serves SFTP sessions on local file system.
+func serveSftpForTest(listener net.Listener, serverConfig *ssh.ServerConfig) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go ssh.DiscardRequests(requests)
+			for newChannel := range channels {
+				if newChannel.ChannelType() != "session" {
+					newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+					continue
+				}
+				channel, channelRequests, err := newChannel.Accept()
+				if err != nil {
+					return
+				}
+				go func(in <-chan *ssh.Request) {
+					for request := range in {
+						// Payload of a subsystem request is a length prefixed name, "sftp" is expected.
+						request.Reply(request.Type == "subsystem" && len(request.Payload) > 4 && string(request.Payload[4:]) == "sftp", nil)
+					}
+				}(channelRequests)
+				go func() {
+					server, err := sftp.NewServer(channel)
+					if err != nil {
+						channel.Close()
+						return
+					}
+					server.Serve()
+					server.Close()
+				}()
+			}
+		}(conn)
+	}
+}
+
+// SftpFileSystemMock keeps files in memory. It doesn't support POSIX rename and, like SFTP rename,
+// fails to rename a file if target already exists.
+type sftpFileSystemMock struct {
+	files         map[string]string
+	failingRename string
+}
+
+func (mock *sftpFileSystemMock) PosixRename(oldname, newname string) error {
+	return errors.New("Unsupported extension")
+}
+
+func (mock *sftpFileSystemMock) Rename(oldname, newname string) error {
+	content, ok := mock.files[oldname]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if _, exists := mock.files[newname]; exists || oldname == mock.failingRename {
+		return os.ErrExist
+	}
+	delete(mock.files, oldname)
+	mock.files[newname] = content
+	return nil
+}
+
+func (mock *sftpFileSystemMock) Remove(path string) error {
+	if _, ok := mock.files[path]; !ok {
+		return os.ErrNotExist
+	}
+	delete(mock.files, path)
+	return nil
+}
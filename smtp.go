@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Supported TLS modes of SMTP connections.
+const (
+	smtpTlsStartTls = "starttls"
+	smtpTlsImplicit = "tls"
+	smtpTlsNone     = "none"
+)
+
+// Supported SMTP authentication mechanisms.
+const (
+	smtpAuthPlain = "plain"
+	smtpAuthLogin = "login"
+	smtpAuthNone  = "none"
+)
+
+// NewSmtpTransport returns a transport which delivers emails to given SMTP server.
+func newSmtpTransport(options smtpOptions) *smtpTransport {
+	return &smtpTransport{
+		options:   options,
+		tlsConfig: &tls.Config{ServerName: options.host},
+	}
+}
+
+// SmtpOptions define server, encryption and authentication for SMTP connections.
+type smtpOptions struct {
+	host string
+	port int
+
+	// Tls is starttls, tls for implicit TLS or none.
+	tls string
+
+	// Auth is an authentication mechanism, plain, login or none.
+	auth string
+
+	username, password string
+
+	// Timeout for establishing a connection and for each read or write on a connection.
+	timeout time.Duration
+}
+
+// SmtpTransport sends emails to a SMTP server.
+type smtpTransport struct {
+	options   smtpOptions
+	tlsConfig *tls.Config
+}
+
+// Send delivers given MIME message to all passed destinations. Source and destinations may contain a display name,
+// only their plain address is used for SMTP commands.
+func (transport *smtpTransport) send(source string, destinations []string, rawEMail []byte) error {
+
+	sourceAddress, err := envelopeAddress(source)
+	if err != nil {
+		return err
+	}
+	destinationAddresses := []string{}
+	for _, destination := range destinations {
+		address, err := envelopeAddress(destination)
+		if err != nil {
+			return err
+		}
+		destinationAddresses = append(destinationAddresses, address)
+	}
+
+	client, err := transport.connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if auth := transport.authentication(); auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(sourceAddress); err != nil {
+		return err
+	}
+	for _, destination := range destinationAddresses {
+		if err := client.Rcpt(destination); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(rawEMail); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Connect opens a connection to a SMTP server. Depending on TLS mode a connection is encrypted
+// right from the start or after STARTTLS command.
+func (transport *smtpTransport) connect() (*smtp.Client, error) {
+
+	address := net.JoinHostPort(transport.options.host, strconv.Itoa(transport.options.port))
+	dialer := &net.Dialer{Timeout: transport.options.timeout}
+
+	var conn net.Conn
+	var err error
+	if transport.options.tls == smtpTlsImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, transport.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if transport.options.timeout > 0 {
+		conn = &deadlineConn{Conn: conn, timeout: transport.options.timeout}
+	}
+
+	client, err := smtp.NewClient(conn, transport.options.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if transport.options.tls == smtpTlsStartTls {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP server doesn't support STARTTLS")
+		}
+		if err := client.StartTLS(transport.tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// EnvelopeAddress returns the plain address of given email address, e.g. user@example.com for "User" <user@example.com>.
+func envelopeAddress(address string) (string, error) {
+	parsedAddress, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return parsedAddress.Address, nil
+}
+
+// DeadlineConn sets a deadline before each read and write, so a connection fails if a SMTP server doesn't respond
+// within a timeout. Using a single deadline for a whole connection could abort sending large attachments.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// Read reads from a connection and fails if no data is received within timeout.
+func (conn *deadlineConn) Read(b []byte) (int, error) {
+	if err := conn.Conn.SetDeadline(time.Now().Add(conn.timeout)); err != nil {
+		return 0, err
+	}
+	return conn.Conn.Read(b)
+}
+
+// Write writes to a connection and fails if data can't be written within timeout.
+func (conn *deadlineConn) Write(b []byte) (int, error) {
+	if err := conn.Conn.SetDeadline(time.Now().Add(conn.timeout)); err != nil {
+		return 0, err
+	}
+	return conn.Conn.Write(b)
+}
+
+// Authentication returns an authentication for configured mechanism, or nil if authentication is disabled.
+func (transport *smtpTransport) authentication() smtp.Auth {
+	switch transport.options.auth {
+	case smtpAuthPlain:
+		return smtp.PlainAuth("", transport.options.username, transport.options.password, transport.options.host)
+	case smtpAuthLogin:
+		return &loginAuth{username: transport.options.username, password: transport.options.password}
+	default:
+		return nil
+	}
+}
+
+// LoginAuth implements SMTP authentication mechanism LOGIN, which isn't supported by net/smtp.
+type loginAuth struct {
+	username, password string
+}
+
+// Start begins LOGIN authentication. Credentials are sent over encrypted connections, only.
+func (auth *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("Unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers username and password challenges of a SMTP server.
+func (auth *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:":
+		return []byte(auth.username), nil
+	case "Password:":
+		return []byte(auth.password), nil
+	default:
+		return nil, fmt.Errorf("Unexpected server challenge: %s", fromServer)
+	}
+}
+
+// IsLocalhost returns true for local SMTP servers, credentials can be sent to them without encryption.
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	config "github.com/tommzn/go-config"
+	secrets "github.com/tommzn/go-secrets"
+)
+
+type SmtpTransportTestSuite struct {
+	suite.Suite
+	certificate tls.Certificate
+	rootCAs     *x509.CertPool
+}
+
+func TestSmtpTransportTestSuite(t *testing.T) {
+	suite.Run(t, new(SmtpTransportTestSuite))
+}
+
+func (suite *SmtpTransportTestSuite) SetupSuite() {
+	suite.certificate, suite.rootCAs = certificateForTest()
+}
+
+func (suite *SmtpTransportTestSuite) TestSendWithStartTls() {
+
+	server := suite.startServer(false)
+	defer server.close()
+
+	transport := suite.transportForTest(server, smtpTlsStartTls, smtpAuthPlain)
+	suite.Nil(transport.send("from@example.com", []string{"to@example.com", "hr@example.com"}, []byte("Subject: Report\r\n\r\nreport\r\n")))
+	server.wait()
+
+	suite.True(server.tls)
+	suite.Equal("user", server.username)
+	suite.Equal("from@example.com", server.from)
+	suite.Equal([]string{"to@example.com", "hr@example.com"}, server.recipients)
+	suite.Contains(server.data, "Subject: Report")
+}
+
+func (suite *SmtpTransportTestSuite) TestSendWithImplicitTls() {
+
+	server := suite.startServer(true)
+	defer server.close()
+
+	transport := suite.transportForTest(server, smtpTlsImplicit, smtpAuthLogin)
+	suite.Nil(transport.send("from@example.com", []string{"to@example.com"}, []byte("Subject: Report\r\n\r\nreport\r\n")))
+	server.wait()
+	suite.True(server.tls)
+	suite.Equal("user", server.username)
+}
+
+func (suite *SmtpTransportTestSuite) TestSendWithoutTls() {
+
+	server := suite.startServer(false)
+	defer server.close()
+
+	transport := suite.transportForTest(server, smtpTlsNone, smtpAuthNone)
+	suite.Nil(transport.send("from@example.com", []string{"to@example.com"}, []byte("Subject: Report\r\n\r\nreport\r\n")))
+	server.wait()
+	suite.False(server.tls)
+	suite.Equal("", server.username)
+}
+
+func (suite *SmtpTransportTestSuite) TestSendToNamedAddresses() {
+
+	server := suite.startServer(false)
+	defer server.close()
+
+	transport := suite.transportForTest(server, smtpTlsStartTls, smtpAuthPlain)
+	rawEMail := []byte("To: \"User\" <to@example.com>\r\nSubject: Report\r\n\r\nreport\r\n")
+	suite.Nil(transport.send("Reports <from@example.com>", []string{"\"User\" <to@example.com>", "hr@example.com"}, rawEMail))
+	server.wait()
+
+	suite.Equal("from@example.com", server.from)
+	suite.Equal([]string{"to@example.com", "hr@example.com"}, server.recipients)
+	suite.Contains(server.data, "To: \"User\" <to@example.com>")
+
+	suite.NotNil(transport.send("from@example.com", []string{"invalid"}, rawEMail))
+}
+
+func (suite *SmtpTransportTestSuite) TestServerTimeout() {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Nil(err)
+	defer listener.Close()
+	go func() {
+		// Accepts a connection but never sends a greeting.
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	transport := newSmtpTransport(smtpOptions{
+		host:    "127.0.0.1",
+		port:    listener.Addr().(*net.TCPAddr).Port,
+		tls:     smtpTlsNone,
+		auth:    smtpAuthNone,
+		timeout: 50 * time.Millisecond,
+	})
+	start := time.Now()
+	suite.NotNil(transport.send("from@example.com", []string{"to@example.com"}, []byte("report")))
+	suite.True(time.Since(start) < time.Second)
+}
+
+func (suite *SmtpTransportTestSuite) TestInvalidCredentials() {
+
+	server := suite.startServer(false)
+	defer server.close()
+
+	transport := suite.transportForTest(server, smtpTlsStartTls, smtpAuthPlain)
+	transport.options.password = "invalid"
+	suite.NotNil(transport.send("from@example.com", []string{"to@example.com"}, []byte("report")))
+
+	transport = suite.transportForTest(server, smtpTlsStartTls, smtpAuthLogin)
+	transport.options.password = "invalid"
+	suite.NotNil(transport.send("from@example.com", []string{"to@example.com"}, []byte("report")))
+}
+
+func (suite *SmtpTransportTestSuite) TestUntrustedCertificate() {
+
+	server := suite.startServer(false)
+	defer server.close()
+
+	transport := suite.transportForTest(server, smtpTlsStartTls, smtpAuthPlain)
+	transport.tlsConfig = &tls.Config{ServerName: "127.0.0.1"}
+	suite.NotNil(transport.send("from@example.com", []string{"to@example.com"}, []byte("report")))
+}
+
+func (suite *SmtpTransportTestSuite) TestMailTransportFromConfig() {
+
+	transport1, err1 := mailTransportFromConfig(emptyConfigForTest(), nil)
+	suite.Nil(err1)
+	suite.IsType(&sesTransport{}, transport1)
+
+	conf, err := config.NewStaticConfigSource(`
+hob:
+  email:
+    transport: smtp
+    smtp:
+      host: smtp.example.com
+      tls: tls
+      auth: login
+`).Load()
+	suite.Nil(err)
+	secretsManager := secrets.NewStaticSecretsManager(map[string]string{"HOB_SMTP_USER": "user", "HOB_SMTP_PASSWORD": "secret"})
+	transport2, err2 := mailTransportFromConfig(conf, secretsManager)
+	suite.Nil(err2)
+	suite.IsType(&smtpTransport{}, transport2)
+	suite.Equal(smtpOptions{host: "smtp.example.com", port: 587, tls: smtpTlsImplicit, auth: smtpAuthLogin, username: "user", password: "secret", timeout: 10 * time.Second}, transport2.(*smtpTransport).options)
+
+	_, err3 := mailTransportFromConfig(conf, secrets.NewStaticSecretsManager(map[string]string{}))
+	suite.NotNil(err3)
+
+	invalidConf, _ := config.NewStaticConfigSource(`
+hob:
+  email:
+    transport: smtp
+    smtp:
+      host: smtp.example.com
+      tls: ssl
+`).Load()
+	_, err4 := mailTransportFromConfig(invalidConf, secretsManager)
+	suite.NotNil(err4)
+
+	unknownConf, _ := config.NewStaticConfigSource(`
+hob:
+  email:
+    transport: pigeon
+`).Load()
+	_, err5 := mailTransportFromConfig(unknownConf, secretsManager)
+	suite.NotNil(err5)
+}
+
+func (suite *SmtpTransportTestSuite) transportForTest(server *smtpServerMock, tlsMode, auth string) *smtpTransport {
+	address := server.listener.Addr().(*net.TCPAddr)
+	transport := newSmtpTransport(smtpOptions{
+		host:     "127.0.0.1",
+		port:     address.Port,
+		tls:      tlsMode,
+		auth:     auth,
+		username: "user",
+		password: "secret",
+		timeout:  time.Second,
+	})
+	transport.tlsConfig.RootCAs = suite.rootCAs
+	return transport
+}
+
+func (suite *SmtpTransportTestSuite) startServer(implicitTls bool) *smtpServerMock {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Nil(err)
+	server := &smtpServerMock{listener: listener, tlsConfig: &tls.Config{Certificates: []tls.Certificate{suite.certificate}}}
+	if implicitTls {
+		server.listener = tls.NewListener(listener, server.tlsConfig)
+		server.tls = true
+	}
+	go server.serve()
+	return server
+}
+
+// SmtpServerMock is a minimal SMTP server which accepts a single user and records all received emails.
+type smtpServerMock struct {
+	sync.Mutex
+	listener   net.Listener
+	tlsConfig  *tls.Config
+	tls        bool
+	username   string
+	from       string
+	recipients []string
+	data       string
+}
+
+func (server *smtpServerMock) close() {
+	server.listener.Close()
+}
+
+func (server *smtpServerMock) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		server.Lock()
+		server.handle(conn)
+		server.Unlock()
+	}
+}
+
+// Wait blocks until current connection has been handled.
+func (server *smtpServerMock) wait() {
+	server.Lock()
+	defer server.Unlock()
+}
+
+func (server *smtpServerMock) handle(conn net.Conn) {
+
+	defer func() { conn.Close() }()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			if !server.tls {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN LOGIN")
+		case "STARTTLS":
+			text.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			server.tls = true
+		case "AUTH":
+			server.authenticate(text, strings.Fields(line)[1:])
+		case "MAIL":
+			server.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			server.recipients = append(server.recipients, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Start mail input")
+			lines, _ := text.ReadDotLines()
+			server.data = strings.Join(lines, "\r\n")
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// Authenticate verifies credentials given with mechanism PLAIN or LOGIN.
+func (server *smtpServerMock) authenticate(text *textproto.Conn, args []string) {
+
+	username, password := "", ""
+	switch strings.ToUpper(args[0]) {
+	case "PLAIN":
+		decoded, _ := base64.StdEncoding.DecodeString(args[1])
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) == 3 {
+			username, password = parts[1], parts[2]
+		}
+	case "LOGIN":
+		text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+		line, _ := text.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+		username = string(decoded)
+		text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+		line, _ = text.ReadLine()
+		decoded, _ = base64.StdEncoding.DecodeString(line)
+		password = string(decoded)
+	}
+	if username == "user" && password == "secret" {
+		server.username = username
+		text.PrintfLine("235 Authentication successful")
+	} else {
+		text.PrintfLine("535 Authentication failed")
+	}
+}
+
+// CertificateForTest creates a self-signed certificate for 127.0.0.1 together with a pool which trusts it.
+func certificateForTest() (tls.Certificate, *x509.CertPool) {
+
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	certificate, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey}, pool
+}
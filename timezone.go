@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+// ValidateTimezone returns with an error if timezone of given locale can't be loaded.
+// A locale without timezone is valid, UTC is used in this case.
+func validateTimezone(locale timetracker.Locale) error {
+	if _, err := loadTimezone(locale); err != nil {
+		return fmt.Errorf("Invalid timezone %s, reason: %s", *locale.Timezone, err)
+	}
+	return nil
+}
+
+// Timezone returns the timezone reports are generated in. It's defined by config key hob.locale.timezone,
+// UTC is used if there's no or an invalid timezone.
+func (handler *ReportGenerator) timezone() *time.Location {
+	if timezone, err := loadTimezone(handler.locale); err == nil && timezone != nil {
+		return timezone
+	}
+	return time.UTC
+}
+
+// InTimezone returns midnight of the date of given time in passed timezone.
+func inTimezone(t time.Time, timezone *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, timezone)
+}
+
+// AsUtcDate returns midnight, in UTC, of the date of given time in its timezone.
+func asUtcDate(t time.Time) time.Time {
+	return inTimezone(t, time.UTC)
+}
+
+// LocalTimeCalculator assigns time tracking records to days in a timezone. Calculator of hob-timetracker assigns
+// records to days in UTC, so records are moved to a UTC day with same date as their local day before calculation
+// and moved back to their original point in time afterwards.
+type localTimeCalculator struct {
+	timetracker.ReportCalculator
+	timezone *time.Location
+}
+
+// WithTimeTrackingRecords applies given records, moved to UTC days with same date as their local day, for calculation.
+func (calculator *localTimeCalculator) WithTimeTrackingRecords(records []timetracker.TimeTrackingRecord) {
+
+	localRecords := make([]timetracker.TimeTrackingRecord, len(records))
+	for idx, record := range records {
+		record.Timestamp = toLocalDay(record.Timestamp, calculator.timezone)
+		localRecords[idx] = record
+	}
+	calculator.ReportCalculator.WithTimeTrackingRecords(localRecords)
+}
+
+// MonthlyReport calculates a report for given year and month. Timestamps of all records in this report
+// are moved back to their original point in time.
+func (calculator *localTimeCalculator) MonthlyReport(year, month int, latestType timetracker.RecordType) (*timetracker.MonthlyReport, error) {
+
+	report, err := calculator.ReportCalculator.MonthlyReport(year, month, latestType)
+	if err != nil {
+		return nil, err
+	}
+	for dayIdx := range report.Days {
+		for idx, event := range report.Days[dayIdx].Events {
+			report.Days[dayIdx].Events[idx].Timestamp = fromLocalDay(event.Timestamp, calculator.timezone)
+		}
+	}
+	return report, nil
+}
+
+// ToLocalDay moves given point in time to a UTC day with same date as its day in passed timezone. Time since
+// midnight of its local day is kept, so durations within a day are correct at days with a DST transition, too.
+// The last hour of a day with 25 hours is moved to the last nanosecond of a day.
+func toLocalDay(t time.Time, timezone *time.Location) time.Time {
+
+	localTime := t.In(timezone)
+	sinceMidnight := localTime.Sub(inTimezone(localTime, timezone))
+	if sinceMidnight >= 24*time.Hour {
+		sinceMidnight = 24*time.Hour - time.Nanosecond
+	}
+	return asUtcDate(localTime).Add(sinceMidnight)
+}
+
+// FromLocalDay moves given point in time, returned by toLocalDay, back to its original point in time.
+func fromLocalDay(t time.Time, timezone *time.Location) time.Time {
+	t = t.UTC()
+	return inTimezone(t, timezone).Add(t.Sub(asUtcDate(t))).UTC()
+}
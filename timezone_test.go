@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	timetracker "github.com/tommzn/hob-timetracker"
+)
+
+type TimezoneTestSuite struct {
+	suite.Suite
+	berlin *time.Location
+}
+
+func TestTimezoneTestSuite(t *testing.T) {
+	suite.Run(t, new(TimezoneTestSuite))
+}
+
+func (suite *TimezoneTestSuite) SetupSuite() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	suite.Nil(err)
+	suite.berlin = berlin
+}
+
+func (suite *TimezoneTestSuite) TestValidateTimezone() {
+
+	suite.Nil(validateTimezone(timetracker.Locale{}))
+	suite.Nil(validateTimezone(timetracker.Locale{Timezone: asStringPtr("Europe/Berlin")}))
+	suite.NotNil(validateTimezone(timetracker.Locale{Timezone: asStringPtr("Europe/Rom")}))
+
+	handler := reportGeneratorForTest()
+	suite.Equal("Europe/Rome", handler.timezone().String())
+	handler.locale.Timezone = nil
+	suite.Equal(time.UTC, handler.timezone())
+}
+
+func (suite *TimezoneTestSuite) TestToLocalDay() {
+
+	afterMidnight := time.Date(2022, 1, 31, 23, 30, 0, 0, time.UTC)
+	suite.Equal(time.Date(2022, 2, 1, 0, 30, 0, 0, time.UTC), toLocalDay(afterMidnight, suite.berlin))
+	suite.Equal(afterMidnight, fromLocalDay(toLocalDay(afterMidnight, suite.berlin), suite.berlin))
+
+	// 03:30 CEST, one and a half hours after DST started at 02:00 CET.
+	springForward := time.Date(2022, 3, 27, 1, 30, 0, 0, time.UTC)
+	suite.Equal(time.Date(2022, 3, 27, 2, 30, 0, 0, time.UTC), toLocalDay(springForward, suite.berlin))
+	suite.Equal(springForward, fromLocalDay(toLocalDay(springForward, suite.berlin), suite.berlin))
+
+	// 02:30 CET, second time of 02:30 after DST ended at 03:00 CEST.
+	fallBack := time.Date(2022, 10, 30, 1, 30, 0, 0, time.UTC)
+	suite.Equal(time.Date(2022, 10, 30, 3, 30, 0, 0, time.UTC), toLocalDay(fallBack, suite.berlin))
+	suite.Equal(fallBack, fromLocalDay(toLocalDay(fallBack, suite.berlin), suite.berlin))
+
+	// 23:30 CET is the 25th hour of this day.
+	endOfLongDay := time.Date(2022, 10, 30, 22, 30, 0, 0, time.UTC)
+	suite.Equal(time.Date(2022, 10, 30, 23, 59, 59, 999999999, time.UTC), toLocalDay(endOfLongDay, suite.berlin))
+}
+
+func (suite *TimezoneTestSuite) TestMonthlyReportInTimezone() {
+
+	calculator := newReportCalulator(suite.localeForTest())
+	suite.IsType(&localTimeCalculator{}, calculator)
+
+	checkIn := time.Date(2022, 1, 31, 23, 15, 0, 0, time.UTC)
+	calculator.WithTimeTrackingRecords(suite.recordsForTest(checkIn, checkIn.Add(8*time.Hour)))
+
+	january, err := calculator.MonthlyReport(2022, 1, timetracker.WORKDAY)
+	suite.Nil(err)
+	suite.Len(january.Days, 0)
+	suite.Equal(time.Duration(0), january.TotalWorkingTime)
+
+	february, err := calculator.MonthlyReport(2022, 2, timetracker.WORKDAY)
+	suite.Nil(err)
+	suite.Equal(timetracker.Date{Year: 2022, Month: 2, Day: 1}, february.Days[0].Date)
+	suite.Equal(8*time.Hour, february.Days[0].WorkingTime)
+	suite.Equal(checkIn, february.Days[0].Events[0].Timestamp)
+	suite.Equal(8*time.Hour, february.TotalWorkingTime)
+
+	suite.IsType(&timetracker.ReportCalulator{}, newReportCalulator(timetracker.Locale{Country: "de"}))
+}
+
+func (suite *TimezoneTestSuite) TestMonthlyReportWithDstTransition() {
+
+	calculator := newReportCalulator(suite.localeForTest())
+	calculator.WithTimeTrackingRecords(suite.recordsForTest(
+		// 01:30 CET - 03:30 CEST
+		time.Date(2022, 3, 27, 0, 30, 0, 0, time.UTC), time.Date(2022, 3, 27, 1, 30, 0, 0, time.UTC),
+		// 00:30 CEST - 02:30 CET, DST ends at 03:00 CEST
+		time.Date(2022, 10, 29, 22, 30, 0, 0, time.UTC), time.Date(2022, 10, 30, 1, 30, 0, 0, time.UTC),
+	))
+
+	march, err := calculator.MonthlyReport(2022, 3, timetracker.WORKDAY)
+	suite.Nil(err)
+	marchDays := daysInMonth(march)
+	suite.Equal(timetracker.Date{Year: 2022, Month: 3, Day: 27}, marchDays[26].Date)
+	suite.Equal(time.Hour, marchDays[26].WorkingTime)
+	suite.Equal(time.Hour, march.TotalWorkingTime)
+
+	october, err := calculator.MonthlyReport(2022, 10, timetracker.WORKDAY)
+	suite.Nil(err)
+	octoberDays := daysInMonth(october)
+	suite.Equal(timetracker.Date{Year: 2022, Month: 10, Day: 30}, octoberDays[29].Date)
+	suite.Equal(3*time.Hour, octoberDays[29].WorkingTime)
+	suite.Equal(time.Duration(0), octoberDays[28].WorkingTime)
+}
+
+func (suite *TimezoneTestSuite) TestPeriodReportInTimezone() {
+
+	newYork, err := time.LoadLocation("America/New_York")
+	suite.Nil(err)
+	locale := suite.localeForTest()
+	locale.Timezone = asStringPtr("America/New_York")
+	pipeline := &reportPipeline{ReportGenerator: reportGeneratorForTest(), calculator: newReportCalulator(locale)}
+
+	// Monday, 2022-01-03 20:00 - 23:00 in New York
+	pipeline.calculator.WithTimeTrackingRecords(suite.recordsForTest(time.Date(2022, 1, 4, 1, 0, 0, 0, time.UTC), time.Date(2022, 1, 4, 4, 0, 0, 0, time.UTC)))
+	start, end, err := weeklyReportTimeRange(&reportRequest{GenerateReportRequest: eventWithWeeklyTypeForTest(""), reportOptions: reportOptions{Week: 1}}, newYork)
+	suite.Nil(err)
+	report, _, err := pipeline.calculatePeriodReport(start, end)
+	suite.Nil(err)
+	suite.Len(report.Days, 7)
+	suite.Equal(timetracker.Date{Year: 2022, Month: 1, Day: 3}, report.Days[0].Date)
+	suite.Equal(3*time.Hour, report.Days[0].WorkingTime)
+	suite.Equal(3*time.Hour, report.TotalWorkingTime)
+}
+
+func (suite *TimezoneTestSuite) localeForTest() timetracker.Locale {
+	return timetracker.Locale{Country: "de", Timezone: asStringPtr("Europe/Berlin"), DefaultWorkTime: 8 * time.Hour}
+}
+
+func (suite *TimezoneTestSuite) recordsForTest(timestamps ...time.Time) []timetracker.TimeTrackingRecord {
+	records := []timetracker.TimeTrackingRecord{}
+	for _, timestamp := range timestamps {
+		records = append(records, timetracker.TimeTrackingRecord{DeviceId: "Device01", Type: timetracker.WORKDAY, Timestamp: timestamp})
+	}
+	return records
+}
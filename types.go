@@ -1,22 +1,61 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"time"
+
 	config "github.com/tommzn/go-config"
 	log "github.com/tommzn/go-log"
+	secrets "github.com/tommzn/go-secrets"
+	core "github.com/tommzn/hob-core"
 	timetracker "github.com/tommzn/hob-timetracker"
 )
 
-// ReportGenerator will fetch time tracking records and generates reports.
+// ReportGenerator will fetch time tracking records and generates reports. It contains all long-lived dependencies,
+// each request is processed by its own reportPipeline.
 type ReportGenerator struct {
 	logger      log.Logger
 	conf        config.Config
 	awsConf     awsConfig
 	deviceIds   []string
 	timeTracker timetracker.TimeTracker
-	calculator  timetracker.ReportCalculator
-	formatter   timetracker.ReportFormatter
-	publisher   []timetracker.ReportPublisher
 	calendar    timetracker.Calendar
+
+	// SecretsManager is used to obtain credentials of delivery targets, e.g. to sign webhook requests.
+	secretsManager secrets.SecretsManager
+
+	// Locale is used to create a calculator for each request.
+	locale timetracker.Locale
+
+	// IdempotencyStore keeps completion state of requests, completed requests are skipped within given TTL.
+	idempotencyStore idempotencyStore
+	idempotencyTtl   time.Duration
+
+	// BatchWorkers is the max number of SQS messages of a batch which are processed at the same time.
+	batchWorkers int
+
+	// SafetyMargin is the min remaining time until a Lambda deadline to start processing of another message.
+	safetyMargin time.Duration
+
+	// FetchWorkers is the max number of devices time tracking records are fetched for at the same time.
+	fetchWorkers int
+}
+
+// ReportPipeline generates a report for a single request. It owns formatters, publishers and a calculator
+// of this request and shares all other dependencies with its report generator.
+type reportPipeline struct {
+	*ReportGenerator
+
+	// Ctx is the context of a request, fetching time tracking records stops if it's done.
+	ctx context.Context
+
+	calculator timetracker.ReportCalculator
+	formatter  []timetracker.ReportFormatter
+	publisher  []timetracker.ReportPublisher
+
+	// Checksums of already delivered reports. If defined, unchanged reports are not delivered again.
+	checksums checksumStore
 }
 
 // AwsConfig used for different AWS clients.
@@ -25,5 +64,257 @@ type awsConfig struct {
 }
 
 type awsEventBridgeTrigger struct {
-	Content string `json:"content"`
+	Content string        `json:"content"`
+	Options reportOptions `json:"options"`
+}
+
+// Report types are open enums in core, so these types can be used until they're part of core.ReportType.
+const (
+
+	// ReportTypeWeekly is used to generate a report for a single ISO week.
+	reportTypeWeekly core.ReportType = 2
+
+	// ReportTypeYearly is used to generate a summary with working time and overtime of all months in a year.
+	reportTypeYearly core.ReportType = 3
+
+	// ReportTypeDateRange is used to generate a report for all days from a start until an end date.
+	reportTypeDateRange core.ReportType = 4
+)
+
+// Report formats are open enums in core, so these formats can be used until they're part of core.ReportFormat.
+const (
+
+	// ReportFormatCsv generates reports as CSV files.
+	reportFormatCsv core.ReportFormat = 2
+
+	// ReportFormatPdf generates reports as PDF files.
+	reportFormatPdf core.ReportFormat = 3
+
+	// ReportFormatHtml generates reports as HTML files.
+	reportFormatHtml core.ReportFormat = 4
+
+	// ReportFormatJson generates reports as JSON files, see schema/report-v1.schema.json.
+	reportFormatJson core.ReportFormat = 5
+)
+
+// Delivery targets defined in config, which can be selected by option "targets" of a request.
+const (
+
+	// DeliveryTargetWebhook posts reports to webhook url defined by config key hob.webhook.url.
+	deliveryTargetWebhook = "webhook"
+
+	// DeliveryTargetSftp uploads reports to SFTP server defined by config key hob.sftp.host.
+	deliveryTargetSftp = "sftp"
+)
+
+// ReportOptions contains settings for a report which are not part of core.GenerateReportRequest, yet.
+type reportOptions struct {
+
+	// Week is an ISO week a weekly report should be generated for.
+	Week int64 `json:"week,omitempty"`
+
+	// StartDate is the first day, format YYYY-MM-DD, of a date range report.
+	StartDate string `json:"startDate,omitempty"`
+
+	// EndDate is the last day, format YYYY-MM-DD, of a date range report.
+	EndDate string `json:"endDate,omitempty"`
+
+	// Formats is a list of additional report formats, e.g. excel, csv, pdf, html or json.
+	Formats []string `json:"formats,omitempty"`
+
+	// Archive packs all generated files into a single ZIP archive before delivery.
+	Archive bool `json:"archive,omitempty"`
+
+	// Mail contains additional settings for email delivery.
+	Mail mailOptions `json:"mail,omitempty"`
+
+	// Chat contains channels a notification should be sent to.
+	Chat chatOptions `json:"chat,omitempty"`
+
+	// Targets is a list of delivery targets defined in config, e.g. webhook, a report should be sent to
+	// in addition to delivery targets of a request.
+	Targets []string `json:"targets,omitempty"`
+
+	// RequestId identifies a request. If it's not defined, a request is identified by its type, period, devices,
+	// formats and delivery targets.
+	RequestId string `json:"requestId,omitempty"`
+
+	// Force generates a report, even if the same request has been completed before.
+	Force bool `json:"force,omitempty"`
+}
+
+// MailOptions are additional settings for reports delivered via email.
+type mailOptions struct {
+
+	// InlineReport embeds a report as HTML into an email body.
+	InlineReport bool `json:"inlineReport,omitempty"`
+
+	// WithoutAttachment will send an email without report file. Use it together with an inline report.
+	WithoutAttachment bool `json:"withoutAttachment,omitempty"`
+}
+
+// ReportRequest is a report generate request together with its additional options.
+type reportRequest struct {
+	*core.GenerateReportRequest
+	reportOptions
+}
+
+// ReportFile is a single, formatted report output.
+type reportFile struct {
+	name    string
+	content []byte
+}
+
+// FormatErrors contains errors for each report format, identified by file extension, which failed.
+type formatErrors map[string]error
+
+// DeviceErrors contains errors for each device, identified by its id, time tracking records couldn't be fetched for.
+type deviceErrors map[string]error
+
+// PeriodReport contains all days of an arbitrary time range, e.g. a week, and total working time of it.
+type periodReport struct {
+
+	// Start is the first point in time of a report period.
+	Start time.Time
+
+	// End is the last point in time of a report period.
+	End time.Time
+
+	// Location a report has been generated for.
+	Location timetracker.Locale
+
+	// Days is a list of all days within a report period.
+	Days []timetracker.Day
+
+	// TotalWorkingTime is the entire working time of a report period.
+	TotalWorkingTime time.Duration
+}
+
+// PeriodReportFormatter is implemented by all formatters which are able to write reports for an arbitrary time range.
+type periodReportFormatter interface {
+
+	// WritePeriodReportToBuffer returns a buffer for generated report output.
+	WritePeriodReportToBuffer(*periodReport) (*bytes.Buffer, error)
+}
+
+// YearlyReport is a summary of working time and overtime for all months of a year.
+type yearlyReport struct {
+
+	// Year this report belongs to.
+	Year int
+
+	// Location a report has been generated for.
+	Location timetracker.Locale
+
+	// Months is a list of summaries for each month of a year.
+	Months []monthlySummary
+
+	// TotalWorkingTime is the entire working time of a year.
+	TotalWorkingTime time.Duration
+
+	// ExpectedWorkingTime is the working time which has to be done in a year.
+	ExpectedWorkingTime time.Duration
+
+	// Overtime is the difference between total and expected working time.
+	Overtime time.Duration
+}
+
+// MonthlySummary contains total, expected and overtime of a single month.
+type monthlySummary struct {
+
+	// Month this summary has been created for.
+	Month int
+
+	// Workdays is the number of days in a month someone is expected to work.
+	// Weekends, holidays, vacation and illness are excluded.
+	Workdays int
+
+	// WorkingTime is the entire working time of a month.
+	WorkingTime time.Duration
+
+	// ExpectedWorkingTime is number of workdays multiplied with default working time.
+	ExpectedWorkingTime time.Duration
+
+	// Overtime is the difference between working time and expected working time of a month.
+	Overtime time.Duration
+
+	// OvertimeBalance is the sum of overtime from beginning of a year until end of this month.
+	OvertimeBalance time.Duration
+}
+
+// YearlyReportFormatter is implemented by all formatters which are able to write yearly reports.
+type yearlyReportFormatter interface {
+
+	// WriteYearlyReportToBuffer returns a buffer for generated report output.
+	WriteYearlyReportToBuffer(*yearlyReport) (*bytes.Buffer, error)
+}
+
+// GeneratedReport is a calculated report together with its time range and all holidays within this range.
+type generatedReport struct {
+
+	// Start is the first point in time of a report.
+	Start time.Time
+
+	// End is the last point in time of a report.
+	End time.Time
+
+	// DeviceIds is a list of devices time tracking records have been fetched for.
+	DeviceIds []string
+
+	// Report is a *timetracker.MonthlyReport, a *periodReport or a *yearlyReport.
+	Report interface{}
+
+	// Holidays is a list of public holidays within report time range.
+	Holidays []timetracker.Holiday
+}
+
+// ReportAwarePublisher is implemented by all publishers which need a calculated report in addition to its formatted output.
+type reportAwarePublisher interface {
+
+	// WithReport assigns a report which has been generated for next delivery.
+	WithReport(*generatedReport) error
+}
+
+// CompletingPublisher is implemented by all publishers which have to be notified after all files of a report have been published.
+type completingPublisher interface {
+
+	// Complete is called after all files have been published.
+	Complete() error
+}
+
+// ContextAwarePublisher is implemented by all publishers which should stop a delivery if context of a request is done.
+type contextAwarePublisher interface {
+
+	// WithContext assigns the context of a request.
+	WithContext(context.Context)
+}
+
+// ChatOptions define chat channels a notification should be sent to after a report has been delivered.
+type chatOptions struct {
+
+	// Channels are names of chat channels defined in config.
+	Channels []string `json:"channels,omitempty"`
+}
+
+// ReportSummary contains period, devices and totals of a report, independent of its type.
+// It's used to render email and chat templates.
+type reportSummary struct {
+	Start, End          time.Time
+	Period              string
+	Devices             []string
+	Locale              timetracker.Locale
+	Workdays            int
+	TotalWorkingTime    time.Duration
+	ExpectedWorkingTime time.Duration
+	Overtime            time.Duration
+}
+
+// ChecksumStore keeps checksums of delivered reports in a delivery target, e.g. in a local directory or a S3 bucket.
+type checksumStore interface {
+
+	// Checksum returns the checksum of a delivered report, or an empty string if there's no checksum for it.
+	checksum(name string) (string, error)
+
+	// SaveChecksum keeps given checksum of a delivered report.
+	saveChecksum(name, checksum string) error
 }
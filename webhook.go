@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	log "github.com/tommzn/go-log"
+)
+
+// WebhookSignatureHeader contains a HMAC-SHA256 signature of a request body, e.g. sha256=<hex encoded signature>.
+const webhookSignatureHeader = "X-Hob-Signature"
+
+// Supported encodings of webhook requests.
+const (
+	webhookEncodingJson      = "json"
+	webhookEncodingMultipart = "multipart"
+)
+
+// NewWebhookPublisher returns a publisher which posts reports to given url.
+func newWebhookPublisher(url string, options webhookOptions, logger log.Logger) *webhookPublisher {
+	return &webhookPublisher{
+		url:     url,
+		options: options,
+		client:  &http.Client{Timeout: options.timeout},
+		logger:  logger,
+	}
+}
+
+// WebhookOptions define encoding, additional headers, signing and retries of webhook requests.
+type webhookOptions struct {
+
+	// Encoding of a request body, json or multipart.
+	encoding string
+
+	// Headers are added to each request, e.g. for authentication.
+	headers map[string]string
+
+	// Secret is used to sign request bodies. Requests are not signed if there's no secret.
+	secret []byte
+
+	// Timeout for a single request.
+	timeout time.Duration
+
+	// Retries is the max number of retries if a request fails with a server error.
+	retries int
+
+	// Backoff is the delay before first retry. It's doubled for each further retry.
+	backoff time.Duration
+}
+
+// WebhookPublisher posts reports to a http endpoint.
+type webhookPublisher struct {
+	url     string
+	options webhookOptions
+
+	// Summary of current report, sent as metadata.
+	summary *reportSummary
+
+	// Ctx is the context of current request. Requests and retries are canceled if it's done.
+	ctx context.Context
+
+	client *http.Client
+	logger log.Logger
+}
+
+// WebhookMetadata describes a report file sent to a webhook.
+type webhookMetadata struct {
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int       `json:"size"`
+	Sha256      string    `json:"sha256"`
+	Period      string    `json:"period,omitempty"`
+	Start       time.Time `json:"start,omitempty"`
+	End         time.Time `json:"end,omitempty"`
+	Devices     []string  `json:"devices,omitempty"`
+}
+
+// WebhookEnvelope is a JSON request body with metadata and a base64 encoded report.
+type webhookEnvelope struct {
+	webhookMetadata
+	Content []byte `json:"content"`
+}
+
+// WithReport keeps a summary of passed report to send it as metadata together with report files.
+func (publisher *webhookPublisher) WithReport(report *generatedReport) error {
+	summary := newReportSummary(report)
+	publisher.summary = &summary
+	return nil
+}
+
+// WithContext assigns the context of current request.
+func (publisher *webhookPublisher) WithContext(ctx context.Context) {
+	publisher.ctx = ctx
+}
+
+// Send posts given report to webhook url. Requests failed because of a network or server error are retried,
+// unless context of current request is done.
+func (publisher *webhookPublisher) Send(content []byte, fileName string) error {
+
+	ctx := publisher.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	body, contentType, err := publisher.requestBody(content, fileName)
+	if err != nil {
+		return err
+	}
+
+	backoff := publisher.options.backoff
+	for attempt := 0; ; attempt++ {
+
+		retryable, err := publisher.post(ctx, body, contentType)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= publisher.options.retries {
+			publisher.logger.Errorf("Unable to send %s to webhook, reason: %s", fileName, err)
+			return err
+		}
+		publisher.logger.Debugf("Webhook request failed, retry in %s, reason: %s", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			publisher.logger.Errorf("Unable to send %s to webhook, reason: %s", fileName, ctx.Err())
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Post sends a single request to webhook url. Returns if a failed request can be retried.
+func (publisher *webhookPublisher) post(ctx context.Context, body []byte, contentType string) (bool, error) {
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, publisher.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	for name, value := range publisher.options.headers {
+		request.Header.Set(name, value)
+	}
+	if len(publisher.options.secret) > 0 {
+		request.Header.Set(webhookSignatureHeader, "sha256="+signature(body, publisher.options.secret))
+	}
+
+	response, err := publisher.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return response.StatusCode >= 500, fmt.Errorf("Webhook returns with status %s", response.Status)
+	}
+	return false, nil
+}
+
+// RequestBody returns a request body with report metadata and content, together with its content type.
+func (publisher *webhookPublisher) requestBody(content []byte, fileName string) ([]byte, string, error) {
+
+	checksum := sha256.Sum256(content)
+	metadata := webhookMetadata{
+		FileName:    fileName,
+		ContentType: contentType(fileName),
+		Size:        len(content),
+		Sha256:      hex.EncodeToString(checksum[:]),
+	}
+	if publisher.summary != nil {
+		metadata.Period = publisher.summary.Period
+		metadata.Start = publisher.summary.Start
+		metadata.End = publisher.summary.End
+		metadata.Devices = publisher.summary.Devices
+	}
+
+	if publisher.options.encoding != webhookEncodingMultipart {
+		body, err := json.Marshal(webhookEnvelope{webhookMetadata: metadata, Content: content})
+		return body, "application/json", err
+	}
+
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	metadataJson, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := writer.WriteField("metadata", string(metadataJson)); err != nil {
+		return nil, "", err
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf("form-data; name=\"report\"; filename=\"%s\"", fileName))
+	header.Set("Content-Type", metadata.ContentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// Signature returns a hex encoded HMAC-SHA256 signature of given body.
+func signature(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	config "github.com/tommzn/go-config"
+	secrets "github.com/tommzn/go-secrets"
+)
+
+type WebhookPublisherTestSuite struct {
+	suite.Suite
+}
+
+func TestWebhookPublisherTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookPublisherTestSuite))
+}
+
+func (suite *WebhookPublisherTestSuite) TestSendJsonEnvelope() {
+
+	var body []byte
+	var request *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	options := webhookOptionsForTest()
+	options.headers = map[string]string{"X-Api-Key": "xxx"}
+	options.secret = []byte("secret")
+	publisher := newWebhookPublisher(server.URL, options, loggerForTest())
+	suite.Nil(publisher.WithReport(generatedReportForTest()))
+	suite.Nil(publisher.Send([]byte("report"), "report_202201.csv"))
+
+	suite.Equal(http.MethodPost, request.Method)
+	suite.Equal("application/json", request.Header.Get("Content-Type"))
+	suite.Equal("xxx", request.Header.Get("X-Api-Key"))
+	suite.Equal("sha256="+signature(body, []byte("secret")), request.Header.Get(webhookSignatureHeader))
+
+	envelope := webhookEnvelope{}
+	suite.Nil(json.Unmarshal(body, &envelope))
+	suite.Equal("report_202201.csv", envelope.FileName)
+	suite.Equal("text/csv", envelope.ContentType)
+	suite.Equal(6, envelope.Size)
+	suite.Equal("2022-01", envelope.Period)
+	suite.Equal([]string{"Device01"}, envelope.Devices)
+	suite.Equal([]byte("report"), envelope.Content)
+}
+
+func (suite *WebhookPublisherTestSuite) TestSendMultipart() {
+
+	var metadata webhookMetadata
+	var content []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Nil(r.ParseMultipartForm(1 << 20))
+		suite.Nil(json.Unmarshal([]byte(r.FormValue("metadata")), &metadata))
+		file, header, err := r.FormFile("report")
+		suite.Nil(err)
+		suite.Equal("report.pdf", header.Filename)
+		content, _ = io.ReadAll(file)
+		suite.Empty(r.Header.Get(webhookSignatureHeader))
+	}))
+	defer server.Close()
+
+	options := webhookOptionsForTest()
+	options.encoding = webhookEncodingMultipart
+	publisher := newWebhookPublisher(server.URL, options, loggerForTest())
+	suite.Nil(publisher.Send([]byte("report"), "report.pdf"))
+	suite.Equal("report.pdf", metadata.FileName)
+	suite.Equal(6, metadata.Size)
+	suite.Equal([]byte("report"), content)
+}
+
+func (suite *WebhookPublisherTestSuite) TestRetryOnServerError() {
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	publisher := newWebhookPublisher(server.URL, webhookOptionsForTest(), loggerForTest())
+	suite.Nil(publisher.Send([]byte("report"), "report.xlsx"))
+	suite.Equal(int32(3), atomic.LoadInt32(&requests))
+}
+
+func (suite *WebhookPublisherTestSuite) TestRetryLimit() {
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	publisher := newWebhookPublisher(server.URL, webhookOptionsForTest(), loggerForTest())
+	suite.NotNil(publisher.Send([]byte("report"), "report.xlsx"))
+	suite.Equal(int32(4), atomic.LoadInt32(&requests))
+}
+
+func (suite *WebhookPublisherTestSuite) TestNoRetryOnClientError() {
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	publisher := newWebhookPublisher(server.URL, webhookOptionsForTest(), loggerForTest())
+	suite.NotNil(publisher.Send([]byte("report"), "report.xlsx"))
+	suite.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func (suite *WebhookPublisherTestSuite) TestTimeout() {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	options := webhookOptionsForTest()
+	options.timeout = 10 * time.Millisecond
+	options.retries = 0
+	publisher := newWebhookPublisher(server.URL, options, loggerForTest())
+	suite.NotNil(publisher.Send([]byte("report"), "report.xlsx"))
+}
+
+func (suite *WebhookPublisherTestSuite) TestStopRetriesIfContextIsDone() {
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	options := webhookOptionsForTest()
+	options.backoff = time.Minute
+	publisher := newWebhookPublisher(server.URL, options, loggerForTest())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	publisher.WithContext(ctx)
+
+	start := time.Now()
+	suite.Equal(context.DeadlineExceeded, publisher.Send([]byte("report"), "report.xlsx"))
+	suite.True(time.Since(start) < time.Second)
+	suite.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func (suite *WebhookPublisherTestSuite) TestWebhookPublisherFromConfig() {
+
+	publisher, err := webhookPublisherFromConfig(emptyConfigForTest(), secretsManagerForTest(), loggerForTest())
+	suite.Nil(publisher)
+	suite.Nil(err)
+
+	conf, err := config.NewStaticConfigSource(`
+hob:
+  webhook:
+    url: https://example.com/reports
+    encoding: multipart
+    retries: 5
+    headers:
+      - name: X-Api-Key
+        value: xxx
+`).Load()
+	suite.Nil(err)
+	secretsManager := secrets.NewStaticSecretsManager(map[string]string{"HOB_WEBHOOK_SECRET": "secret"})
+	publisher, err = webhookPublisherFromConfig(conf, secretsManager, loggerForTest())
+	suite.Nil(err)
+	suite.NotNil(publisher)
+	suite.Equal("https://example.com/reports", publisher.url)
+	suite.Equal(webhookEncodingMultipart, publisher.options.encoding)
+	suite.Equal(5, publisher.options.retries)
+	suite.Equal(10*time.Second, publisher.options.timeout)
+	suite.Equal(map[string]string{"X-Api-Key": "xxx"}, publisher.options.headers)
+	suite.Equal([]byte("secret"), publisher.options.secret)
+
+	publisher, err = webhookPublisherFromConfig(conf, secrets.NewStaticSecretsManager(map[string]string{}), loggerForTest())
+	suite.Nil(err)
+	suite.Len(publisher.options.secret, 0)
+
+	// A failing secrets manager must not disable request signing.
+	publisher, err = webhookPublisherFromConfig(conf, secrets.NewFileSecretsManager(suite.T().TempDir()+"/missing"), loggerForTest())
+	suite.Nil(publisher)
+	suite.NotNil(err)
+}
+
+func webhookOptionsForTest() webhookOptions {
+	return webhookOptions{
+		encoding: webhookEncodingJson,
+		timeout:  time.Second,
+		retries:  3,
+		backoff:  time.Millisecond,
+	}
+}